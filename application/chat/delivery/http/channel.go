@@ -0,0 +1,149 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/transport"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultDirectoryLimit = 20
+
+// @Description list and search the public channels by name and topic
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param q query string false "search in name and topic"
+// @Param limit query int false "channels per page, 20 by default and 100 at most"
+// @Param offset query int false "channels to skip"
+// @Produce json
+// @Success 200
+// @Router /chat/channels [get]
+func (handler *ChatHttpApi) GetChannelDirectory(c echo.Context) error {
+	var query transport.ChannelDirectory
+
+	if c.Bind(&query) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := query.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultDirectoryLimit
+	}
+
+	directory, err := handler.chatUseCase.GetChannelDirectory(
+		c.Request().Context(),
+		chat.ChannelDirectoryParam{
+			CurrentUserID: userID,
+			Query:         query.Query,
+			Limit:         limit,
+			Offset:        query.Offset,
+		},
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    directory,
+	})
+}
+
+// @Description join a public channel
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "channel room id"
+// @Produce json
+// @Success 200
+// @Router /chat/channels/{room_id}/join [post]
+func (handler *ChatHttpApi) JoinChannel(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	member, err := handler.chatUseCase.JoinChannel(
+		c.Request().Context(),
+		chat.JoinChannelParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrChannelNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrAlreadyRoomMember):
+			return c.JSON(http.StatusConflict, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    member,
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/realtime"
 	"github.com/fikrihkll/chat-app/application/chat/repositories"
 	"github.com/fikrihkll/chat-app/application/chat/transport"
 	"github.com/fikrihkll/chat-app/application/chat/usecases"
@@ -15,13 +16,19 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// maxLongPollWait caps the wait param of GetMessage, in seconds
+const maxLongPollWait = 60
+
 type ChatHttpApi struct {
-	chatUseCase chat.IChatUseCase
-	authUseCase chat.IAuthUseCase
+	chatUseCase     chat.IChatUseCase
+	authUseCase     chat.IAuthUseCase
+	presenceUseCase chat.IPresenceUseCase
+	inviteUseCase   chat.IInviteUseCase
+	hub             *realtime.Hub
 }
 
-func NewChatHttpApi(chatUseCase chat.IChatUseCase, authUseCase chat.IAuthUseCase) *ChatHttpApi {
-	return &ChatHttpApi{chatUseCase, authUseCase}
+func NewChatHttpApi(chatUseCase chat.IChatUseCase, authUseCase chat.IAuthUseCase, presenceUseCase chat.IPresenceUseCase, inviteUseCase chat.IInviteUseCase, hub *realtime.Hub) *ChatHttpApi {
+	return &ChatHttpApi{chatUseCase, authUseCase, presenceUseCase, inviteUseCase, hub}
 }
 
 // @Description gain access to API
@@ -204,6 +211,7 @@ func (handler *ChatHttpApi) SaveMessageByEmail(c echo.Context) error {
 			CurrentUserEmail: userEmail,
 			MemberEmail:      body.MemberEmail,
 			Message:          body.Message,
+			ReplyToMessageID: body.ReplyTo,
 		},
 	); err != nil {
 		if err == common.UserNotFoundError {
@@ -212,6 +220,20 @@ func (handler *ChatHttpApi) SaveMessageByEmail(c echo.Context) error {
 				Data:    nil,
 			})	
 		}
+
+		if errors.Is(err, usecases.ErrQuoteNotInRoom) {
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		if errors.Is(err, usecases.ErrMessageDeleted) {
+			return c.JSON(http.StatusConflict, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
 		
 		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
 			Message: common.InternalServerError.Error(),
@@ -266,14 +288,45 @@ func (handler *ChatHttpApi) SaveMessageByRoomID(c echo.Context) error {
 		})
 	}
 
+	userEmail, ok := c.Get("email").(string)
+	if !ok || userEmail == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
 	if err := handler.chatUseCase.SaveMessageByRoomID(
 		c.Request().Context(),
 		chat.NewMessageByRoomIDParam{
-			CurrentUserID: userID,
-			RoomID:        roomID.String(),
-			Message:       body.Message,
+			CurrentUserID:    userID,
+			CurrentUserEmail: userEmail,
+			RoomID:           roomID.String(),
+			Message:          body.Message,
+			ReplyToMessageID: body.ReplyTo,
 		},
 	); err != nil {
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		if errors.Is(err, usecases.ErrQuoteNotInRoom) {
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		if errors.Is(err, usecases.ErrMessageDeleted) {
+			return c.JSON(http.StatusConflict, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
 		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
 			Message: common.InternalServerError.Error(),
 			Data:    nil,
@@ -286,12 +339,278 @@ func (handler *ChatHttpApi) SaveMessageByRoomID(c echo.Context) error {
 	})
 }
 
+// @Description tell the other room members that the user is typing
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/typing [post]
+func (handler *ChatHttpApi) SendTypingIndicator(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userEmail, ok := c.Get("email").(string)
+	if !ok || userEmail == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := handler.chatUseCase.SendTypingIndicator(
+		c.Request().Context(),
+		chat.TypingParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: userEmail,
+			RoomID:           roomID.String(),
+		},
+	); err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrTooManyTypingIndicators):
+			return c.JSON(http.StatusTooManyRequests, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    nil,
+	})
+}
+
+// @Description mark the room as read up to a message and send a read receipt to the room
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param read body transport.MarkRead true "Last read message"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/read [post]
+func (handler *ChatHttpApi) MarkRead(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.MarkRead
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userEmail, ok := c.Get("email").(string)
+	if !ok || userEmail == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	cursor, err := handler.chatUseCase.MarkRead(
+		c.Request().Context(),
+		chat.MarkReadParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: userEmail,
+			RoomID:           roomID.String(),
+			MessageID:        body.MessageID,
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotInRoom):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    cursor,
+	})
+}
+
+// @Description acknowledge that messages reached the device, their senders get notified
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param ack body transport.DeliveryAck true "Delivered messages"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/messages/delivered [post]
+func (handler *ChatHttpApi) AcknowledgeDelivery(c echo.Context) error {
+	var body transport.DeliveryAck
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := handler.chatUseCase.AcknowledgeDelivery(
+		c.Request().Context(),
+		chat.DeliveryAckParam{
+			CurrentUserID: userID,
+			MessageIDs:    body.MessageIDs,
+		},
+	); err != nil {
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    nil,
+	})
+}
+
+// @Description get the sent, delivered or read state of a message for each recipient
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id}/receipts [get]
+func (handler *ChatHttpApi) GetMessageReceipts(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	receipts, err := handler.chatUseCase.GetMessageReceipts(
+		c.Request().Context(),
+		chat.MessageReceiptsParam{
+			CurrentUserEmail: email,
+			MessageID:        messageID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    receipts,
+	})
+}
+
 // @Description get message history
 // @Security BearerAuth
 // @Tags chat
 // @Param Authorization header string true "Bearer token"
 // @Param time_after query string true "timestamp last message retrieved"
-// @Param target_email query string true "user that is in the same chat room"
+// @Param target_email query string false "user that is in the same two-member chat room, required without room_id"
+// @Param room_id query string false "room id, required without target_email"
+// @Param wait query int false "seconds to wait for a new message when there is none yet"
 // @Accept json
 // @Produce json
 // @Success 200
@@ -299,14 +618,24 @@ func (handler *ChatHttpApi) SaveMessageByRoomID(c echo.Context) error {
 func (handler *ChatHttpApi) GetMessage(c echo.Context) error {
 	timeAfterStr := c.QueryParam("time_after")
 	targetEmail := c.QueryParam("target_email")
+	roomID := c.QueryParam("room_id")
 
-	if targetEmail == "" {
+	if targetEmail == "" && roomID == "" {
 		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
 			Message: common.BadRequestError.Error(),
 			Data:    nil,
 		})
 	}
 
+	if roomID != "" {
+		if _, err := uuid.Parse(roomID); err != nil {
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: common.BadRequestError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
 	if timeAfterStr == "" {
 		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
 			Message: common.BadRequestError.Error(),
@@ -330,14 +659,28 @@ func (handler *ChatHttpApi) GetMessage(c echo.Context) error {
 		})
 	}
 
-	messages, err := handler.chatUseCase.GetMessages(
-		c.Request().Context(),
-		chat.MessageHistoryParams{
-			TargetEmail:      targetEmail,
-			TimeAfter:        int64(timeAfter),
-			CurrentUserEmail: email,
-		},
-	)
+	wait := 0
+	if waitStr := c.QueryParam("wait"); waitStr != "" {
+		wait, err = strconv.Atoi(waitStr)
+		if err != nil || wait < 0 {
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: common.BadRequestError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	params := chat.MessageHistoryParams{
+		TargetEmail:      targetEmail,
+		RoomID:           roomID,
+		TimeAfter:        int64(timeAfter),
+		CurrentUserEmail: email,
+	}
+
+	messages, err := handler.chatUseCase.GetMessages(c.Request().Context(), params)
+	if err == nil && len(messages) == 0 && wait > 0 {
+		messages, err = handler.waitForMessages(c, params, min(wait, maxLongPollWait))
+	}
 
 	if err != nil {
 		if err == repositories.ErrRoomNotFound {
@@ -347,6 +690,13 @@ func (handler *ChatHttpApi) GetMessage(c echo.Context) error {
 			})
 		}
 
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
 		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
 			Message: common.InternalServerError.Error(),
 			Data:    nil,
@@ -359,7 +709,71 @@ func (handler *ChatHttpApi) GetMessage(c echo.Context) error {
 	})
 }
 
-// @Description get chat rooms that the user in
+// @Description create a group room with the current user and the given members
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param room body transport.NewRoom true "Room detail"
+// @Accept json
+// @Produce json
+// @Success 201
+// @Router /chat/rooms [post]
+func (handler *ChatHttpApi) CreateRoom(c echo.Context) error {
+	var body transport.NewRoom
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	room, err := handler.chatUseCase.CreateRoom(
+		c.Request().Context(),
+		chat.CreateRoomParam{
+			CurrentUserEmail: email,
+			Name:             body.Name,
+			Visibility:       body.Visibility,
+			MemberEmails:     body.MemberEmails,
+		},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrRoomMemberNotFound), errors.Is(err, usecases.ErrRoomTooSmall):
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusCreated, &common.BaseResponse{
+		Message: common.HttpSuccessCreated,
+		Data:    room,
+	})
+}
+
+// @Description get chat rooms that the user in, with the unread count and last read message of each
 // @Security BearerAuth
 // @Tags chat
 // @Param Authorization header string true "Bearer token"
@@ -394,6 +808,26 @@ func (handler *ChatHttpApi) GetRoomsByID(c echo.Context) error {
 	})
 }
 
+// isRoomForbidden tells whether the room policy turned the user away.
+func isRoomForbidden(err error) bool {
+	return errors.Is(err, usecases.ErrNotRoomMember) ||
+		errors.Is(err, usecases.ErrRoomPermissionDenied) ||
+		errors.Is(err, usecases.ErrRoomArchived) ||
+		errors.Is(err, usecases.ErrBannedFromRoom)
+}
+
+// currentUserID reads the user id that AuthMiddleware put in the context
+func currentUserID(c echo.Context) (userID uuid.UUID, ok bool) {
+	id, ok := c.Get("id").(string)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(id)
+	ok = err == nil
+	return
+}
+
 func (handler *ChatHttpApi) HandleRootRoute(e *echo.Echo) {
 	e.GET("/ping", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, &common.BaseResponse{Message: "pong", Data: nil})
@@ -407,6 +841,38 @@ func (handler *ChatHttpApi) HandleChatRoute(e *echo.Echo) {
 	g.POST("/:room_id/send", handler.SaveMessageByRoomID, middleware.AuthMiddleware)
 	g.GET("/get", handler.GetMessage, middleware.AuthMiddleware)
 	g.GET("/rooms", handler.GetRoomsByID, middleware.AuthMiddleware)
+	g.POST("/rooms", handler.CreateRoom, middleware.AuthMiddleware)
+	g.PATCH("/rooms/:room_id", handler.UpdateRoom, middleware.AuthMiddleware)
+	g.GET("/rooms/:room_id/members", handler.GetRoomMembers, middleware.AuthMiddleware)
+	g.PUT("/rooms/:room_id/members/:user_id/role", handler.UpdateMemberRole, middleware.AuthMiddleware)
+	g.DELETE("/rooms/:room_id/members/me", handler.LeaveRoom, middleware.AuthMiddleware)
+	g.DELETE("/rooms/:room_id/members/:user_id", handler.RemoveMember, middleware.AuthMiddleware)
+	g.GET("/rooms/:room_id/bans", handler.GetRoomBans, middleware.AuthMiddleware)
+	g.PUT("/rooms/:room_id/notifications", handler.UpdateNotificationSettings, middleware.AuthMiddleware)
+	g.GET("/channels", handler.GetChannelDirectory, middleware.AuthMiddleware)
+	g.POST("/channels/:room_id/join", handler.JoinChannel, middleware.AuthMiddleware)
+	g.PUT("/rooms/:room_id/bans/:user_id", handler.BanMember, middleware.AuthMiddleware)
+	g.DELETE("/rooms/:room_id/bans/:user_id", handler.UnbanMember, middleware.AuthMiddleware)
+	g.POST("/rooms/:room_id/invites", handler.CreateInvite, middleware.AuthMiddleware)
+	g.GET("/rooms/:room_id/invites", handler.GetRoomInvites, middleware.AuthMiddleware)
+	g.DELETE("/rooms/:room_id/invites/:invite_id", handler.RevokeInvite, middleware.AuthMiddleware)
+	g.GET("/rooms/:room_id/invites/:invite_id/redemptions", handler.GetInviteRedemptions, middleware.AuthMiddleware)
+	g.POST("/invites/:token/accept", handler.AcceptInvite, middleware.AuthMiddleware)
+	g.GET("/ws", handler.ServeWebSocket, middleware.QueryTokenMiddleware, middleware.AuthMiddleware)
+	g.POST("/rooms/:room_id/typing", handler.SendTypingIndicator, middleware.AuthMiddleware)
+	g.POST("/rooms/:room_id/read", handler.MarkRead, middleware.AuthMiddleware)
+	g.POST("/messages/delivered", handler.AcknowledgeDelivery, middleware.AuthMiddleware)
+	g.GET("/messages/:message_id/receipts", handler.GetMessageReceipts, middleware.AuthMiddleware)
+	g.PATCH("/messages/:message_id", handler.EditMessage, middleware.AuthMiddleware)
+	g.GET("/messages/:message_id/revisions", handler.GetMessageRevisions, middleware.AuthMiddleware)
+	g.DELETE("/messages/:message_id", handler.DeleteMessage, middleware.AuthMiddleware)
+	g.POST("/messages/:message_id/purge", handler.PurgeMessage, middleware.AuthMiddleware)
+	g.PUT("/messages/:message_id/reactions/:emoji", handler.AddReaction, middleware.AuthMiddleware)
+	g.DELETE("/messages/:message_id/reactions/:emoji", handler.RemoveReaction, middleware.AuthMiddleware)
+	g.GET("/messages/:message_id/thread", handler.GetThread, middleware.AuthMiddleware)
+	g.POST("/messages/:message_id/thread", handler.ReplyInThread, middleware.AuthMiddleware)
+	g.POST("/messages/:message_id/thread/read", handler.MarkThreadRead, middleware.AuthMiddleware)
+	g.GET("/rooms/:room_id/events", handler.StreamRoomEvents, middleware.QueryTokenMiddleware, middleware.AuthMiddleware)
 }
 
 func (handler *ChatHttpApi) HandleAuthRoute(e *echo.Echo) {
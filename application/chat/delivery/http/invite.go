@@ -0,0 +1,352 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/transport"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// @Description create an invite link to the room, admins and owners only
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param invite body transport.NewInvite true "Invite limits"
+// @Accept json
+// @Produce json
+// @Success 201
+// @Router /chat/rooms/{room_id}/invites [post]
+func (handler *ChatHttpApi) CreateInvite(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.NewInvite
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	invite, err := handler.inviteUseCase.CreateInvite(
+		c.Request().Context(),
+		chat.CreateInviteParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			MaxUses:          body.MaxUses,
+			ExpiresIn:        time.Duration(body.ExpiresInHours) * time.Hour,
+		},
+	)
+	if err != nil {
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, &common.BaseResponse{
+		Message: common.HttpSuccessCreated,
+		Data:    invite,
+	})
+}
+
+// @Description list the invite links of the room that can still be used, admins and owners only
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/invites [get]
+func (handler *ChatHttpApi) GetRoomInvites(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	invites, err := handler.inviteUseCase.GetRoomInvites(
+		c.Request().Context(),
+		chat.RoomMembersParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+		},
+	)
+	if err != nil {
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    invites,
+	})
+}
+
+// @Description revoke an invite link, admins and owners only
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param invite_id path string true "invite id"
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/invites/{invite_id} [delete]
+func (handler *ChatHttpApi) RevokeInvite(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	inviteID, err := uuid.Parse(c.Param("invite_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	err = handler.inviteUseCase.RevokeInvite(
+		c.Request().Context(),
+		chat.RoomInviteParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			InviteID:         inviteID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrInviteNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    nil,
+	})
+}
+
+// @Description list who joined the room through an invite link, admins and owners only
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param invite_id path string true "invite id"
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/invites/{invite_id}/redemptions [get]
+func (handler *ChatHttpApi) GetInviteRedemptions(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	inviteID, err := uuid.Parse(c.Param("invite_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	redemptions, err := handler.inviteUseCase.GetInviteRedemptions(
+		c.Request().Context(),
+		chat.RoomInviteParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			InviteID:         inviteID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrInviteNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    redemptions,
+	})
+}
+
+// @Description join the room of an invite link
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param token path string true "invite token"
+// @Produce json
+// @Success 200
+// @Router /chat/invites/{token}/accept [post]
+func (handler *ChatHttpApi) AcceptInvite(c echo.Context) error {
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	member, err := handler.inviteUseCase.AcceptInvite(
+		c.Request().Context(),
+		chat.AcceptInviteParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			Token:            c.Param("token"),
+		},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrInvalidInvite):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrInviteExpired), errors.Is(err, usecases.ErrInviteRevoked), errors.Is(err, usecases.ErrInviteUsedUp):
+			return c.JSON(http.StatusGone, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrAlreadyRoomMember):
+			return c.JSON(http.StatusConflict, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    member,
+	})
+}
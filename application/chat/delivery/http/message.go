@@ -0,0 +1,476 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/transport"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// @Description edit a message you sent, only possible for a while after sending it
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Param message body transport.EditMessage true "New content"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id} [patch]
+func (handler *ChatHttpApi) EditMessage(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.EditMessage
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	message, err := handler.chatUseCase.EditMessage(
+		c.Request().Context(),
+		chat.EditMessageParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			MessageID:        messageID.String(),
+			Content:          body.Message,
+		},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrMessageNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case isRoomForbidden(err), errors.Is(err, usecases.ErrNotMessageSender), errors.Is(err, usecases.ErrMessageNotEditable), errors.Is(err, usecases.ErrEditWindowExpired):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    message,
+	})
+}
+
+// @Description list the previous versions of an edited message, admins and owners only
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id}/revisions [get]
+func (handler *ChatHttpApi) GetMessageRevisions(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	revisions, err := handler.chatUseCase.GetMessageRevisions(
+		c.Request().Context(),
+		chat.MessageRevisionsParam{
+			CurrentUserEmail: email,
+			MessageID:        messageID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    revisions,
+	})
+}
+
+// @Description delete a message, senders can delete their own and admins anyone's. The message stays in history with an empty content
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id} [delete]
+func (handler *ChatHttpApi) DeleteMessage(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	message, err := handler.chatUseCase.DeleteMessage(
+		c.Request().Context(),
+		chat.DeleteMessageParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			MessageID:        messageID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    message,
+	})
+}
+
+// @Description remove a message from history for good without leaving a tombstone, admins and owners only
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id}/purge [post]
+func (handler *ChatHttpApi) PurgeMessage(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	purged, err := handler.chatUseCase.PurgeMessage(
+		c.Request().Context(),
+		chat.DeleteMessageParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			MessageID:        messageID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    purged,
+	})
+}
+
+// @Description react to a message with an emoji, reacting twice with the same emoji changes nothing
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Param emoji path string true "url encoded emoji"
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id}/reactions/{emoji} [put]
+func (handler *ChatHttpApi) AddReaction(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	// the router leaves the param escaped when the client encoded it differently
+	emoji, err := url.PathUnescape(c.Param("emoji"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	body := transport.Reaction{Emoji: emoji}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	reaction, err := handler.chatUseCase.AddReaction(
+		c.Request().Context(),
+		chat.ReactionParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			MessageID:        messageID.String(),
+			Emoji:            body.Emoji,
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageDeleted):
+			return c.JSON(http.StatusConflict, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    reaction,
+	})
+}
+
+// @Description remove your reaction to a message
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Param emoji path string true "url encoded emoji"
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id}/reactions/{emoji} [delete]
+func (handler *ChatHttpApi) RemoveReaction(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	// the router leaves the param escaped when the client encoded it differently
+	emoji, err := url.PathUnescape(c.Param("emoji"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	body := transport.Reaction{Emoji: emoji}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	err = handler.chatUseCase.RemoveReaction(
+		c.Request().Context(),
+		chat.ReactionParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			MessageID:        messageID.String(),
+			Emoji:            body.Emoji,
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound), errors.Is(err, usecases.ErrReactionNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageDeleted):
+			return c.JSON(http.StatusConflict, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    nil,
+	})
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fikrihkll/chat-app/application/chat/transport"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/fikrihkll/chat-app/common/middleware"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// @Description get whether a user sharing a room with the current user is online, away or offline and when they were last seen
+// @Security BearerAuth
+// @Tags user
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "user id"
+// @Produce json
+// @Success 200
+// @Router /users/{id}/presence [get]
+func (handler *ChatHttpApi) GetPresence(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	presence, err := handler.presenceUseCase.GetPresence(c.Request().Context(), email, userID.String())
+	if err != nil {
+		if errors.Is(err, common.UserNotFoundError) {
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    presence,
+	})
+}
+
+// @Description get the presence of every user sharing a room with the current user
+// @Security BearerAuth
+// @Tags user
+// @Param Authorization header string true "Bearer token"
+// @Produce json
+// @Success 200
+// @Router /users/presence [get]
+func (handler *ChatHttpApi) GetRoomMatesPresence(c echo.Context) error {
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	presences, err := handler.presenceUseCase.GetRoomMatesPresence(c.Request().Context(), email)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    presences,
+	})
+}
+
+// @Description set the current user as online, away or offline while connected
+// @Security BearerAuth
+// @Tags user
+// @Param Authorization header string true "Bearer token"
+// @Param presence body transport.UpdatePresence true "Presence status"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /users/me/presence [put]
+func (handler *ChatHttpApi) UpdatePresence(c echo.Context) error {
+	var body transport.UpdatePresence
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := handler.presenceUseCase.SetStatus(c.Request().Context(), userID, body.Status); err != nil {
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    nil,
+	})
+}
+
+func (handler *ChatHttpApi) HandleUserRoute(e *echo.Echo) {
+	g := e.Group("/users")
+
+	g.GET("/presence", handler.GetRoomMatesPresence, middleware.AuthMiddleware)
+	g.PUT("/me/presence", handler.UpdatePresence, middleware.AuthMiddleware)
+	g.GET("/:id/presence", handler.GetPresence, middleware.AuthMiddleware)
+}
@@ -0,0 +1,363 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/realtime"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = (wsPongWait * 9) / 10
+	wsMaxMessageSize = 4096
+
+	sseHeartbeatPeriod = 30 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// clients authenticate with a bearer token, not cookies
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// @Description open a websocket that receives new messages of every room the user is in
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string false "Bearer token"
+// @Param access_token query string false "token for browsers, which cannot set headers on a websocket"
+// @Success 101
+// @Router /chat/ws [get]
+func (handler *ChatHttpApi) ServeWebSocket(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// the upgrader already replied to the client
+		common.Log(common.LOG_LEVEL_WARN, err.Error())
+		return nil
+	}
+
+	sub := handler.hub.Subscribe(userID)
+	defer sub.Close()
+
+	heartbeat, disconnect := handler.trackPresence(c.Request().Context(), userID)
+	defer disconnect()
+
+	closed := make(chan struct{})
+	go readWebSocket(conn, closed)
+	writeWebSocket(conn, sub, closed, heartbeat)
+
+	return nil
+}
+
+// trackPresence counts the connection towards the user being online until
+// disconnect is called, heartbeat keeps it from expiring meanwhile. Presence
+// failures are only logged so they never cost the user their connection.
+func (handler *ChatHttpApi) trackPresence(ctx context.Context, userID uuid.UUID) (heartbeat func(), disconnect func()) {
+	connectionID, err := handler.presenceUseCase.Connect(ctx, userID.String())
+	if err != nil {
+		common.Log(common.LOG_LEVEL_WARN, err.Error())
+		return func() {}, func() {}
+	}
+
+	heartbeat = func() {
+		if err := handler.presenceUseCase.Heartbeat(context.Background(), userID.String(), connectionID); err != nil {
+			common.Log(common.LOG_LEVEL_WARN, err.Error())
+		}
+	}
+
+	disconnect = func() {
+		// the request context is usually gone by now
+		if err := handler.presenceUseCase.Disconnect(context.Background(), userID.String(), connectionID); err != nil {
+			common.Log(common.LOG_LEVEL_WARN, err.Error())
+		}
+	}
+
+	return
+}
+
+// readWebSocket keeps the read deadline moving on pongs and signals closed
+// as soon as the client goes away.
+func readWebSocket(conn *websocket.Conn, closed chan<- struct{}) {
+	defer close(closed)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func writeWebSocket(conn *websocket.Conn, sub *realtime.Subscription, closed <-chan struct{}, heartbeat func()) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case event := <-sub.Events():
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			heartbeat()
+		case <-closed:
+			return
+		case <-sub.Done():
+			conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "connection too slow"),
+				time.Now().Add(wsWriteWait),
+			)
+			return
+		}
+	}
+}
+
+// @Description stream new messages of a room as server-sent events, replaying missed ones from Last-Event-ID or sending a stream.reset when they cannot all be replayed
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string false "Bearer token"
+// @Param access_token query string false "token for clients that cannot set headers"
+// @Param Last-Event-ID header string false "id of the last event received"
+// @Param room_id path string true "room id"
+// @Produce text/event-stream
+// @Success 200
+// @Router /chat/rooms/{room_id}/events [get]
+func (handler *ChatHttpApi) StreamRoomEvents(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+	if _, err := uuid.Parse(lastEventID); err != nil {
+		lastEventID = ""
+	}
+
+	// subscribe before reading the backlog so nothing slips in between
+	sub := handler.hub.Subscribe(userID)
+	defer sub.Close()
+
+	missed, reset, err := handler.chatUseCase.GetMissedMessages(c.Request().Context(), chat.RoomEventsParam{
+		RoomID:           roomID.String(),
+		CurrentUserEmail: email,
+		LastEventID:      lastEventID,
+	})
+	if err != nil {
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	heartbeat, disconnect := handler.trackPresence(c.Request().Context(), userID)
+	defer disconnect()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if reset != nil {
+		// the id moves the client past what it has to fetch again, an empty
+		// room has nothing to move past
+		streamID := ""
+		if reset.LastMessageID != nil {
+			streamID = reset.LastMessageID.String()
+		}
+
+		if err := writeServerSentEvent(res, streamID, chat.Event{
+			ID:        uuid.NewString(),
+			Type:      chat.EventStreamReset,
+			RoomID:    roomID,
+			Data:      reset,
+			CreatedAt: time.Now(),
+		}); err != nil {
+			return nil
+		}
+	}
+
+	replayed := map[string]bool{}
+	for _, message := range missed {
+		event := chat.NewMessageCreatedEvent(message, nil)
+		if err := writeServerSentEvent(res, streamEventID(event), event); err != nil {
+			return nil
+		}
+		replayed[message.ID.String()] = true
+	}
+	res.Flush()
+
+	ticker := time.NewTicker(sseHeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-sub.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+			heartbeat()
+		case event := <-sub.Events():
+			if event.RoomID != roomID || replayed[event.ID] {
+				continue
+			}
+			if err := writeServerSentEvent(res, streamEventID(event), event); err != nil {
+				return nil
+			}
+			res.Flush()
+
+			// the removed member gets the removal as the last event of the room
+			if event.Type == chat.EventMemberRemoved {
+				_, _, err := handler.chatUseCase.GetMissedMessages(c.Request().Context(), chat.RoomEventsParam{
+					RoomID:           roomID.String(),
+					CurrentUserEmail: email,
+				})
+				if isRoomForbidden(err) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// streamEventID is the id a stream resumes from, only messages can be
+// replayed so every other event leaves the client's last id alone.
+func streamEventID(event chat.Event) string {
+	if event.Type != chat.EventMessageCreated {
+		return ""
+	}
+
+	return event.ID
+}
+
+// writeServerSentEvent leaves the id field out when id is empty, an empty id
+// field would clear the client's last event id.
+func writeServerSentEvent(w io.Writer, id string, event chat.Event) (err error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	if id != "" {
+		if _, err = fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return
+}
+
+// waitForMessages blocks until a new message reaches the user and the history
+// query returns something, the wait expires, or the client goes away.
+func (handler *ChatHttpApi) waitForMessages(c echo.Context, params chat.MessageHistoryParams, waitSeconds int) (messages []chat.Message, err error) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		err = common.UnauthorizedError
+		return
+	}
+
+	sub := handler.hub.Subscribe(userID)
+	defer sub.Close()
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), time.Duration(waitSeconds)*time.Second)
+	defer cancel()
+
+	for {
+		// checked again once subscribed so a message saved in between is not missed
+		messages, err = handler.chatUseCase.GetMessages(ctx, params)
+		if err != nil || len(messages) > 0 {
+			if ctx.Err() != nil {
+				err = nil
+			}
+			return
+		}
+
+		if !waitForMessageEvent(ctx, sub) {
+			return
+		}
+	}
+}
+
+func waitForMessageEvent(ctx context.Context, sub *realtime.Subscription) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-sub.Done():
+			return false
+		case event := <-sub.Events():
+			if event.Type == chat.EventMessageCreated {
+				return true
+			}
+		}
+	}
+}
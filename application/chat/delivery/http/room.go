@@ -0,0 +1,656 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/transport"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// @Description get the members of a room with their role
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/members [get]
+func (handler *ChatHttpApi) GetRoomMembers(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	members, err := handler.chatUseCase.GetRoomMemberships(
+		c.Request().Context(),
+		chat.RoomMembersParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+		},
+	)
+	if err != nil {
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    members,
+	})
+}
+
+// @Description change the role of a room member, only owners can
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param user_id path string true "member user id"
+// @Param role body transport.UpdateMemberRole true "New role"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/members/{user_id}/role [put]
+func (handler *ChatHttpApi) UpdateMemberRole(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.UpdateMemberRole
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	member, err := handler.chatUseCase.UpdateMemberRole(
+		c.Request().Context(),
+		chat.UpdateMemberRoleParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			UserID:           userID.String(),
+			Role:             body.Role,
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrRoomMemberNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrChangeOwnRole):
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    member,
+	})
+}
+
+// @Description change the name, topic, description, avatar, visibility or message retention of a room, or archive it to make it read-only. Admins and owners only
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param room body transport.UpdateRoom true "Settings to change"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id} [patch]
+func (handler *ChatHttpApi) UpdateRoom(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.UpdateRoom
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	room, err := handler.chatUseCase.UpdateRoom(
+		c.Request().Context(),
+		chat.UpdateRoomParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			Name:             body.Name,
+			Topic:            body.Topic,
+			Description:      body.Description,
+			AvatarURL:        body.AvatarURL,
+			Visibility:       body.Visibility,
+			RetentionDays:    body.RetentionDays,
+			Archived:         body.Archived,
+		},
+	)
+	if err != nil {
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    room,
+	})
+}
+
+// @Description leave a room, the last owner has to hand the room over first
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/members/me [delete]
+func (handler *ChatHttpApi) LeaveRoom(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	err = handler.chatUseCase.LeaveRoom(
+		c.Request().Context(),
+		chat.RoomMembersParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrLastOwner):
+			return c.JSON(http.StatusConflict, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    nil,
+	})
+}
+
+// @Description remove a member from a room, admins and owners only and only members with a lower role
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param user_id path string true "member user id"
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/members/{user_id} [delete]
+func (handler *ChatHttpApi) RemoveMember(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	err = handler.chatUseCase.RemoveMember(
+		c.Request().Context(),
+		chat.RoomMemberParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			UserID:           userID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrRoomMemberNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrRemoveSelf):
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    nil,
+	})
+}
+
+// @Description list the users banned from a room, admins and owners only
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/bans [get]
+func (handler *ChatHttpApi) GetRoomBans(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	bans, err := handler.chatUseCase.GetRoomBans(
+		c.Request().Context(),
+		chat.RoomMembersParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+		},
+	)
+	if err != nil {
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    bans,
+	})
+}
+
+// @Description ban a user from a room, removing them if they are in it. Admins and owners only
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param user_id path string true "user id"
+// @Param ban body transport.BanMember false "Reason of the ban"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/bans/{user_id} [put]
+func (handler *ChatHttpApi) BanMember(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.BanMember
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	ban, err := handler.chatUseCase.BanMember(
+		c.Request().Context(),
+		chat.BanMemberParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			UserID:           userID.String(),
+			Reason:           body.Reason,
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, common.UserNotFoundError):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrRemoveSelf):
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    ban,
+	})
+}
+
+// @Description lift the ban of a user, they can join again through an invite. Admins and owners only
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param user_id path string true "user id"
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/bans/{user_id} [delete]
+func (handler *ChatHttpApi) UnbanMember(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	err = handler.chatUseCase.UnbanMember(
+		c.Request().Context(),
+		chat.RoomMemberParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			UserID:           userID.String(),
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrBanNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    nil,
+	})
+}
+
+// @Description change how the current user is notified about a room, or mute it for a while or until further notice
+// @Security BearerAuth
+// @Tags room
+// @Param Authorization header string true "Bearer token"
+// @Param room_id path string true "room id"
+// @Param notifications body transport.UpdateNotifications true "Settings to change"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/rooms/{room_id}/notifications [put]
+func (handler *ChatHttpApi) UpdateNotificationSettings(c echo.Context) error {
+	roomID, err := uuid.Parse(c.Param("room_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.UpdateNotifications
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var muteFor time.Duration
+	if body.MuteForMinutes != nil {
+		muteFor = time.Duration(*body.MuteForMinutes) * time.Minute
+	}
+
+	settings, err := handler.chatUseCase.UpdateNotificationSettings(
+		c.Request().Context(),
+		chat.UpdateNotificationsParam{
+			CurrentUserEmail: email,
+			RoomID:           roomID.String(),
+			Level:            body.Level,
+			Muted:            body.Muted,
+			MuteFor:          muteFor,
+		},
+	)
+	if err != nil {
+		if isRoomForbidden(err) {
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
+		return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+			Message: common.InternalServerError.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    settings,
+	})
+}
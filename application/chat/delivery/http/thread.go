@@ -0,0 +1,290 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/transport"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultThreadLimit = 50
+
+// @Description reply in the thread of a message, replying to a reply posts in the same thread
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Param message body transport.NewMessageByRoomID true "Reply"
+// @Accept json
+// @Produce json
+// @Success 201
+// @Router /chat/messages/{message_id}/thread [post]
+func (handler *ChatHttpApi) ReplyInThread(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.NewMessageByRoomID
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	message, err := handler.chatUseCase.ReplyInThread(
+		c.Request().Context(),
+		chat.ThreadReplyParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			ParentMessageID:  messageID.String(),
+			Content:          body.Message,
+			ReplyToMessageID: body.ReplyTo,
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrQuoteNotInRoom):
+			return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageDeleted):
+			return c.JSON(http.StatusConflict, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusCreated, &common.BaseResponse{
+		Message: common.HttpSuccessCreated,
+		Data:    message,
+	})
+}
+
+// @Description get the replies in the thread of a message, oldest first, with the thread read state
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Param limit query int false "replies per page, 50 by default and 100 at most"
+// @Param offset query int false "replies to skip"
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id}/thread [get]
+func (handler *ChatHttpApi) GetThread(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var query transport.ThreadPage
+
+	if c.Bind(&query) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := query.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultThreadLimit
+	}
+
+	thread, err := handler.chatUseCase.GetThread(
+		c.Request().Context(),
+		chat.ThreadParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			MessageID:        messageID.String(),
+			Limit:            limit,
+			Offset:           query.Offset,
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    thread,
+	})
+}
+
+// @Description mark the thread of a message as read up to a reply, the room read state is left alone
+// @Security BearerAuth
+// @Tags chat
+// @Param Authorization header string true "Bearer token"
+// @Param message_id path string true "message id"
+// @Param read body transport.MarkRead true "Last read reply"
+// @Accept json
+// @Produce json
+// @Success 200
+// @Router /chat/messages/{message_id}/thread/read [post]
+func (handler *ChatHttpApi) MarkThreadRead(c echo.Context) error {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body transport.MarkRead
+
+	if c.Bind(&body) != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: common.BadRequestError.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := body.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, &common.BaseResponse{
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userID, ok := c.Get("id").(string)
+	if !ok || userID == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusUnauthorized, &common.BaseResponse{
+			Message: common.UnauthorizedError.Error(),
+			Data:    nil,
+		})
+	}
+
+	cursor, err := handler.chatUseCase.MarkThreadRead(
+		c.Request().Context(),
+		chat.MarkThreadReadParam{
+			CurrentUserID:    userID,
+			CurrentUserEmail: email,
+			ParentMessageID:  messageID.String(),
+			MessageID:        body.MessageID,
+		},
+	)
+	if err != nil {
+		switch {
+		case isRoomForbidden(err):
+			return c.JSON(http.StatusForbidden, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case errors.Is(err, usecases.ErrMessageNotFound), errors.Is(err, usecases.ErrMessageNotInThread):
+			return c.JSON(http.StatusNotFound, &common.BaseResponse{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return c.JSON(http.StatusInternalServerError, &common.BaseResponse{
+				Message: common.InternalServerError.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, &common.BaseResponse{
+		Message: common.HttpSuccess,
+		Data:    cursor,
+	})
+}
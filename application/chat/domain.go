@@ -1,36 +1,412 @@
 package chat
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Event types
+const (
+	EventMessageCreated  = "message.created"
+	EventTypingStarted   = "typing.started"
+	EventPresenceChanged = "presence.changed"
+	EventMessageRead     = "message.read"
+	EventMessageStatus   = "message.status"
+	EventMemberJoined    = "member.joined"
+	EventRoomUpdated     = "room.updated"
+	EventMemberRemoved   = "member.removed"
+	EventMessageUpdated  = "message.updated"
+	EventMessageDeleted  = "message.deleted"
+	EventMessagePurged   = "message.purged"
+	EventReactionAdded   = "reaction.added"
+	EventReactionRemoved = "reaction.removed"
+	// EventStreamReset is only sent on event streams, never published
+	EventStreamReset = "stream.reset"
+)
+
+// Stream reset reasons
+const (
+	StreamResetUnknownLastEvent = "unknown_last_event"
+	StreamResetTooManyMissed    = "too_many_missed"
+)
+
+// Presence statuses
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// Room roles, from the most to the least privileged
+const (
+	RoomRoleOwner  = "owner"
+	RoomRoleAdmin  = "admin"
+	RoomRoleMember = "member"
+)
+
+// Room kinds, a direct room is the one conversation between two users
+const (
+	RoomKindGroup  = "group"
+	RoomKindDirect = "direct"
+)
+
+// Room visibilities, public channels are listed in the directory and anyone
+// can join them
+const (
+	RoomVisibilityPrivate = "private"
+	RoomVisibilityPublic  = "public"
+)
+
+// Notification levels a member picks for a room
+const (
+	NotificationLevelAll      = "all"
+	NotificationLevelMentions = "mentions"
+	NotificationLevelNone     = "none"
+)
+
+// Message kinds, system messages tell the room about changes made by UserID
+const (
+	MessageKindUser   = "user"
+	MessageKindSystem = "system"
+)
+
+// Message statuses, a message is delivered or read once every other room
+// member has received or read it
+const (
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+)
+
 // Model
 type Message struct {
 	ID        uuid.UUID `json:"id"`
 	UserID    uuid.UUID `json:"user_id"`
 	RoomID    uuid.UUID `json:"room_id"`
 	Content   string    `json:"content"`
+	Kind      string    `json:"kind"`
+	Status    string    `json:"status,omitempty"`
+	Edited    bool      `json:"edited"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedAt time.Time `json:"updated_at"` // time of the last edit on edited messages
+
+	// a deleted message stays in history as a tombstone with an empty content
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *uuid.UUID `json:"deleted_by,omitempty"`
+
+	// replies have the root message of their thread as parent, only root
+	// messages get replies
+	ParentMessageID *uuid.UUID `json:"parent_message_id,omitempty"`
+	ReplyCount      int        `json:"reply_count"`
+	LastReplyAt     *time.Time `json:"last_reply_at,omitempty"`
+
+	// ReplyTo quotes another message of the same room inline
+	ReplyTo *QuotedMessage `json:"reply_to,omitempty"`
+
+	// Reactions is only filled in history, grouped by emoji
+	Reactions []ReactionSummary `json:"reactions,omitempty"`
 }
 
-type Room struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Users     []string  `json:"users"`
+// QuoteDeletedExcerpt stands in for the quoted content once the original is
+// deleted or purged.
+const QuoteDeletedExcerpt = "original deleted"
+
+// QuotedMessage is a short snapshot of the message a reply quotes, it always
+// shows the current state of the original.
+type QuotedMessage struct {
+	ID      uuid.UUID  `json:"id"`
+	UserID  *uuid.UUID `json:"user_id,omitempty"`
+	Excerpt string     `json:"excerpt"`
+	Deleted bool       `json:"deleted"`
+}
+
+// ReactionSummary counts the members who reacted to a message with an emoji,
+// Reacted tells whether the caller is one of them.
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// MessageReaction is one member reacting to a message with an emoji.
+type MessageReaction struct {
+	MessageID uuid.UUID `json:"message_id"`
+	RoomID    uuid.UUID `json:"room_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Emoji     string    `json:"emoji"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type User struct {
+// PurgedMessage tells clients to drop a message removed from history for good.
+type PurgedMessage struct {
+	ID       uuid.UUID `json:"id"`
+	RoomID   uuid.UUID `json:"room_id"`
+	PurgedAt time.Time `json:"purged_at"`
+	// ReplyIDs lists the thread replies purged along with a root
+	ReplyIDs []uuid.UUID `json:"reply_ids,omitempty"`
+}
+
+// MessageRevision is a version a message had before it was edited.
+type MessageRevision struct {
 	ID        uuid.UUID `json:"id"`
+	MessageID uuid.UUID `json:"message_id"`
+	Content   string    `json:"content"`
+	EditedBy  uuid.UUID `json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"` // when this version was replaced
+}
+
+// MessageReceipt is the state of a message for one of its recipients.
+type MessageReceipt struct {
+	MessageID   uuid.UUID  `json:"message_id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Status      string     `json:"status"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	ReadAt      *time.Time `json:"read_at"`
+}
+
+// MessageStatusUpdate tells the sender that the aggregated status changed.
+type MessageStatusUpdate struct {
+	MessageID uuid.UUID `json:"message_id"`
+	RoomID    uuid.UUID `json:"room_id"`
+	Status    string    `json:"status"`
+}
+
+// MessageStatusBatch carries every status change of a sender's messages in
+// a room that one receipt caused.
+type MessageStatusBatch struct {
+	RoomID   uuid.UUID             `json:"room_id"`
+	Messages []MessageStatusUpdate `json:"messages"`
+}
+
+type Room struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Topic       string     `json:"topic"`
+	Description string     `json:"description"`
+	AvatarURL   string     `json:"avatar_url"`
+	ArchivedAt  *time.Time `json:"archived_at"` // archived rooms are read-only
+	Kind        string     `json:"kind"`
+	Visibility  string     `json:"visibility"`
+	Users       []string   `json:"users"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// messages older than RetentionDays are purged, nil keeps them forever
+	RetentionDays *int `json:"retention_days"`
+
+	// unread state and notification settings of the user listing the rooms
+	UnreadCount     int                   `json:"unread_count"`
+	LastReadMessage *Message              `json:"last_read_message"`
+	Notifications   *NotificationSettings `json:"notifications,omitempty"`
+}
+
+// NotificationSettings is how a member wants to be notified about a room.
+// A muted room without MutedUntil stays muted until the member unmutes it.
+type NotificationSettings struct {
+	Level      string     `json:"level"`
+	Muted      bool       `json:"muted"`
+	MutedUntil *time.Time `json:"muted_until"`
+}
+
+// Notifies tells whether a message, mentioning the member or not, should
+// notify them. Anything that notifies members has to ask it first.
+func (settings NotificationSettings) Notifies(mentioned bool, now time.Time) bool {
+	if settings.Muted && (settings.MutedUntil == nil || settings.MutedUntil.After(now)) {
+		return false
+	}
+
+	switch settings.Level {
+	case NotificationLevelAll:
+		return true
+	case NotificationLevelMentions:
+		return mentioned
+	default:
+		return false
+	}
+}
+
+type RoomMember struct {
+	RoomID   uuid.UUID `json:"room_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// RetentionReport is what the retention sweeper purged in a room, or would
+// have purged in a dry run.
+type RetentionReport struct {
+	RoomID   uuid.UUID `json:"room_id"`
+	Before   time.Time `json:"before"`
+	Messages int64     `json:"messages"`
+	DryRun   bool      `json:"dry_run"`
+}
+
+// PublicChannel is a public room as listed in the directory, without the
+// member list that only members get to see.
+type PublicChannel struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Topic       string    `json:"topic"`
+	Description string    `json:"description"`
+	AvatarURL   string    `json:"avatar_url"`
+	MemberCount int       `json:"member_count"`
+	IsMember    bool      `json:"is_member"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ChannelDirectory struct {
+	Channels []PublicChannel `json:"channels"`
+	Total    int             `json:"total"`
+	Limit    int             `json:"limit"`
+	Offset   int             `json:"offset"`
+}
+
+// Reasons a member is no longer in a room
+const (
+	MemberRemovalLeft    = "left"
+	MemberRemovalRemoved = "removed"
+	MemberRemovalBanned  = "banned"
+)
+
+// MemberRemoval tells the room, and the user who is gone, why they are no
+// longer a member.
+type MemberRemoval struct {
+	RoomID    uuid.UUID `json:"room_id"`
+	UserID    uuid.UUID `json:"user_id"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
-	Password  string    `json:"-"`
+	Reason    string    `json:"reason"`
+	RemovedBy uuid.UUID `json:"removed_by"`
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+// RoomBan keeps a user out of the room until they are unbanned.
+type RoomBan struct {
+	RoomID    uuid.UUID `json:"room_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	BannedBy  uuid.UUID `json:"banned_by"`
+	Reason    string    `json:"reason"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RoomInvite lets anyone holding its token join the room until it expires,
+// runs out of uses or is revoked.
+type RoomInvite struct {
+	ID        uuid.UUID  `json:"id"`
+	RoomID    uuid.UUID  `json:"room_id"`
+	CreatedBy uuid.UUID  `json:"created_by"`
+	Token     string     `json:"token"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type InviteRedemption struct {
+	InviteID   uuid.UUID `json:"invite_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// ReadCursor is the last message a user has read in a room, everything
+// after it that others sent is unread.
+type ReadCursor struct {
+	RoomID    uuid.UUID `json:"room_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	MessageID uuid.UUID `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+// ThreadReadCursor is the last reply of a thread a user has read, threads are
+// read apart from their room.
+type ThreadReadCursor struct {
+	ParentMessageID uuid.UUID `json:"parent_message_id"`
+	UserID          uuid.UUID `json:"user_id"`
+	MessageID       uuid.UUID `json:"message_id"`
+	ReadAt          time.Time `json:"read_at"`
+}
+
+// Thread is a page of the replies to a root message, oldest first.
+type Thread struct {
+	Root        Message           `json:"root"`
+	Replies     []Message         `json:"replies"`
+	Total       int               `json:"total"`
+	Limit       int               `json:"limit"`
+	Offset      int               `json:"offset"`
+	ReadCursor  *ThreadReadCursor `json:"read_cursor"`
+	UnreadCount int               `json:"unread_count"`
+}
+
+// DirectRoomKey identifies the direct room of two users whatever the order
+// they are given in.
+func DirectRoomKey(userID uuid.UUID, otherUserID uuid.UUID) string {
+	ids := []string{userID.String(), otherUserID.String()}
+	slices.Sort(ids)
+	return strings.Join(ids, ":")
+}
+
+// Event is a realtime notification pushed to the members of a room.
+// Recipients is resolved by the publisher and never sent to clients.
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	RoomID     uuid.UUID   `json:"room_id"`
+	Data       any         `json:"data"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Recipients []uuid.UUID `json:"-"`
+}
+
+// StreamReset tells a reconnecting stream that the missed messages were not
+// replayed, the client has to fetch the history again. LastMessageID is the
+// newest message of the room, the stream goes on from there.
+type StreamReset struct {
+	RoomID        uuid.UUID  `json:"room_id"`
+	Reason        string     `json:"reason"`
+	LastMessageID *uuid.UUID `json:"last_message_id"`
+}
+
+func NewMessageCreatedEvent(message Message, recipients []uuid.UUID) Event {
+	return Event{
+		ID:         message.ID.String(),
+		Type:       EventMessageCreated,
+		RoomID:     message.RoomID,
+		Data:       message,
+		CreatedAt:  message.CreatedAt,
+		Recipients: recipients,
+	}
+}
+
+// TypingIndicator is never persisted, clients drop it once ExpiresAt passes.
+type TypingIndicator struct {
+	RoomID    uuid.UUID `json:"room_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type User struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Password   string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+}
+
+type Presence struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
 }
 
 // Param
@@ -44,17 +420,191 @@ type NewMessageByEmailParam struct {
 	CurrentUserEmail string
 	MemberEmail      string
 	Message          string
+	ReplyToMessageID string
 }
 
 type NewMessageByRoomIDParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	RoomID           string
+	Message          string
+	ReplyToMessageID string
+}
+
+type TypingParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	RoomID           string
+}
+
+type MarkReadParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	RoomID           string
+	MessageID        string
+}
+
+type DeliveryAckParam struct {
 	CurrentUserID string
-	RoomID        string
-	Message       string
+	MessageIDs    []string
+}
+
+type EditMessageParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	MessageID        string
+	Content          string
+}
+
+type DeleteMessageParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	MessageID        string
+}
+
+type ReactionParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	MessageID        string
+	Emoji            string
+}
+
+type ThreadReplyParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	ParentMessageID  string
+	Content          string
+	ReplyToMessageID string
+}
+
+type ThreadParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	MessageID        string
+	Limit            int
+	Offset           int
+}
+
+type MarkThreadReadParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	ParentMessageID  string
+	MessageID        string
+}
+
+type MessageRevisionsParam struct {
+	CurrentUserEmail string
+	MessageID        string
+}
+
+type MessageReceiptsParam struct {
+	CurrentUserEmail string
+	MessageID        string
+}
+
+type RoomEventsParam struct {
+	RoomID           string
+	CurrentUserEmail string
+	LastEventID      string
+}
+
+type CreateRoomParam struct {
+	CurrentUserEmail string
+	Name             string
+	Visibility       string
+	MemberEmails     []string
+}
+
+// ChannelDirectoryParam searches public channels by name and topic, an
+// empty Query lists them all.
+type ChannelDirectoryParam struct {
+	CurrentUserID string
+	Query         string
+	Limit         int
+	Offset        int
+}
+
+type JoinChannelParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	RoomID           string
+}
+
+type UpdateMemberRoleParam struct {
+	CurrentUserEmail string
+	RoomID           string
+	UserID           string
+	Role             string
+}
+
+// UpdateRoomParam only changes the fields that are set.
+type UpdateRoomParam struct {
+	CurrentUserEmail string
+	RoomID           string
+	Name             *string
+	Topic            *string
+	Description      *string
+	AvatarURL        *string
+	Visibility       *string
+	RetentionDays    *int // 0 turns retention off
+	Archived         *bool
+}
+
+// UpdateNotificationsParam only changes what is set. Muting without MuteFor
+// mutes the room until further notice.
+type UpdateNotificationsParam struct {
+	CurrentUserEmail string
+	RoomID           string
+	Level            *string
+	Muted            *bool
+	MuteFor          time.Duration
+}
+
+type RoomMembersParam struct {
+	CurrentUserEmail string
+	RoomID           string
+}
+
+// RoomMemberParam targets another member of the room.
+type RoomMemberParam struct {
+	CurrentUserEmail string
+	RoomID           string
+	UserID           string
+}
+
+type BanMemberParam struct {
+	CurrentUserEmail string
+	RoomID           string
+	UserID           string
+	Reason           string
+}
+
+type CreateInviteParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	RoomID           string
+	MaxUses          int
+	ExpiresIn        time.Duration
+}
+
+type RoomInviteParam struct {
+	CurrentUserEmail string
+	RoomID           string
+	InviteID         string
+}
+
+type AcceptInviteParam struct {
+	CurrentUserID    string
+	CurrentUserEmail string
+	Token            string
 }
 
+// MessageHistoryParams targets either a room by id or the two-member room
+// shared with TargetEmail.
 type MessageHistoryParams struct {
 	TimeAfter        int64
 	TargetEmail      string
+	RoomID           string
 	CurrentUserEmail string
 }
 
@@ -2,6 +2,9 @@ package chat
 
 import (
 	"context"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type IChatUseCase interface {
@@ -9,6 +12,42 @@ type IChatUseCase interface {
 	SaveMessageByEmail(ctx context.Context, newMessage NewMessageByEmailParam) (err error)
 	GetMessages(ctx context.Context, params MessageHistoryParams) (messages []Message, err error)
 	GetRoomsByID(ctx context.Context, currentUserEmail string) (rooms []Room, err error)
+	// GetMissedMessages returns a reset instead of the messages when the last
+	// event is unknown or too many messages were missed.
+	GetMissedMessages(ctx context.Context, params RoomEventsParam) (messages []Message, reset *StreamReset, err error)
+	SendTypingIndicator(ctx context.Context, params TypingParam) (err error)
+	MarkRead(ctx context.Context, params MarkReadParam) (cursor ReadCursor, err error)
+	AcknowledgeDelivery(ctx context.Context, params DeliveryAckParam) (err error)
+	GetMessageReceipts(ctx context.Context, params MessageReceiptsParam) (receipts []MessageReceipt, err error)
+	CreateRoom(ctx context.Context, params CreateRoomParam) (room Room, err error)
+	GetRoomMemberships(ctx context.Context, params RoomMembersParam) (members []RoomMember, err error)
+	UpdateMemberRole(ctx context.Context, params UpdateMemberRoleParam) (member RoomMember, err error)
+	UpdateRoom(ctx context.Context, params UpdateRoomParam) (room Room, err error)
+	LeaveRoom(ctx context.Context, params RoomMembersParam) (err error)
+	RemoveMember(ctx context.Context, params RoomMemberParam) (err error)
+	BanMember(ctx context.Context, params BanMemberParam) (ban RoomBan, err error)
+	UnbanMember(ctx context.Context, params RoomMemberParam) (err error)
+	GetRoomBans(ctx context.Context, params RoomMembersParam) (bans []RoomBan, err error)
+	GetChannelDirectory(ctx context.Context, params ChannelDirectoryParam) (directory ChannelDirectory, err error)
+	JoinChannel(ctx context.Context, params JoinChannelParam) (member RoomMember, err error)
+	UpdateNotificationSettings(ctx context.Context, params UpdateNotificationsParam) (settings NotificationSettings, err error)
+	EditMessage(ctx context.Context, params EditMessageParam) (message Message, err error)
+	GetMessageRevisions(ctx context.Context, params MessageRevisionsParam) (revisions []MessageRevision, err error)
+	DeleteMessage(ctx context.Context, params DeleteMessageParam) (message Message, err error)
+	PurgeMessage(ctx context.Context, params DeleteMessageParam) (purged PurgedMessage, err error)
+	AddReaction(ctx context.Context, params ReactionParam) (reaction MessageReaction, err error)
+	RemoveReaction(ctx context.Context, params ReactionParam) (err error)
+	ReplyInThread(ctx context.Context, params ThreadReplyParam) (message Message, err error)
+	GetThread(ctx context.Context, params ThreadParam) (thread Thread, err error)
+	MarkThreadRead(ctx context.Context, params MarkThreadReadParam) (cursor ThreadReadCursor, err error)
+}
+
+type IInviteUseCase interface {
+	CreateInvite(ctx context.Context, params CreateInviteParam) (invite RoomInvite, err error)
+	GetRoomInvites(ctx context.Context, params RoomMembersParam) (invites []RoomInvite, err error)
+	RevokeInvite(ctx context.Context, params RoomInviteParam) (err error)
+	GetInviteRedemptions(ctx context.Context, params RoomInviteParam) (redemptions []InviteRedemption, err error)
+	AcceptInvite(ctx context.Context, params AcceptInviteParam) (member RoomMember, err error)
 }
 
 type IAuthUseCase interface {
@@ -16,15 +55,154 @@ type IAuthUseCase interface {
 	Login(ctx context.Context, loginParam LoginParam) (data LoginResponse, err error)
 }
 
+type IPresenceUseCase interface {
+	Connect(ctx context.Context, userID string) (connectionID string, err error)
+	Heartbeat(ctx context.Context, userID string, connectionID string) (err error)
+	Disconnect(ctx context.Context, userID string, connectionID string) (err error)
+	SetStatus(ctx context.Context, userID string, status string) (err error)
+	// GetPresence only answers for the user themselves and their room mates.
+	GetPresence(ctx context.Context, currentUserEmail string, userID string) (presence Presence, err error)
+	GetRoomMatesPresence(ctx context.Context, currentUserEmail string) (presences []Presence, err error)
+	// ExpireConnections takes offline the users whose connections all
+	// expired without a disconnect, like those of a crashed instance.
+	ExpireConnections(ctx context.Context) (err error)
+}
+
 type IUserRepository interface {
 	CreateUser(ctx context.Context, user User) (err error)
 	GetUserByEmail(ctx context.Context, email string) (user User, err error)
 	GetUserByID(ctx context.Context, id string) (user User, err error)
+	UpdateLastSeen(ctx context.Context, id string, lastSeenAt time.Time) (err error)
 }
 
 type IChatRepository interface {
-	InsertMessageByRoomID(ctx context.Context, newMessage NewMessageByRoomIDParam) (err error)
-	InsertMessageByEmail(ctx context.Context, newMessage NewMessageByEmailParam, targetUser User) (err error)
+	InsertMessageByRoomID(ctx context.Context, newMessage NewMessageByRoomIDParam) (message Message, err error)
+	InsertMessageByEmail(ctx context.Context, newMessage NewMessageByEmailParam, targetUser User) (message Message, err error)
+	// GetMessage reads the history of params.RoomID.
 	GetMessage(ctx context.Context, params MessageHistoryParams) (messages []Message, err error)
+	// GetDirectRoomID returns the direct room between the two users whether or
+	// not they are still in it.
+	GetDirectRoomID(ctx context.Context, currentUserEmail string, targetEmail string) (roomID string, err error)
 	GetRoomsByID(ctx context.Context, currentUsetEmail string) (rooms []Room, err error)
+	// InsertRoom makes ownerEmail the owner of the room and the others members.
+	InsertRoom(ctx context.Context, name string, visibility string, ownerEmail string, memberEmails []string) (room Room, err error)
+	// GetRoomByID returns sql.ErrNoRows when the room does not exist.
+	GetRoomByID(ctx context.Context, roomID string) (room Room, err error)
+	// UpdateRoom saves the name, topic, description, avatar, visibility,
+	// retention and archived_at of the room.
+	UpdateRoom(ctx context.Context, room Room) (updated Room, err error)
+	InsertSystemMessage(ctx context.Context, roomID string, userID string, content string) (message Message, err error)
+	GetRoomMembers(ctx context.Context, roomID string) (members []User, err error)
+	IsRoomMember(ctx context.Context, roomID string, userEmail string) (isMember bool, err error)
+	// GetRoomMember returns sql.ErrNoRows when the user is not in the room.
+	GetRoomMember(ctx context.Context, roomID string, userEmail string) (member RoomMember, err error)
+	GetRoomMemberships(ctx context.Context, roomID string) (members []RoomMember, err error)
+	UpdateMemberRole(ctx context.Context, roomID string, userID string, role string) (member RoomMember, err error)
+	// UpdateNotificationSettings leaves nil fields as they are, mutedUntil is
+	// only saved along with muted. It returns sql.ErrNoRows when the user is
+	// not in the room.
+	UpdateNotificationSettings(ctx context.Context, roomID string, userID string, level *string, muted *bool, mutedUntil *time.Time) (settings NotificationSettings, err error)
+	InsertRoomMember(ctx context.Context, roomID string, userID string) (member RoomMember, err error)
+	// DeleteRoomMember returns sql.ErrNoRows when the user is not in the room.
+	DeleteRoomMember(ctx context.Context, roomID string, userID string) (err error)
+	// InsertRoomBan bans the user and takes them out of the room in one go,
+	// banning them again only updates the reason.
+	InsertRoomBan(ctx context.Context, ban RoomBan) (created RoomBan, err error)
+	// DeleteRoomBan returns sql.ErrNoRows when the user is not banned.
+	DeleteRoomBan(ctx context.Context, roomID string, userID string) (err error)
+	GetRoomBans(ctx context.Context, roomID string) (bans []RoomBan, err error)
+	IsBannedFromRoom(ctx context.Context, roomID string, userID string) (isBanned bool, err error)
+	// GetRoomsWithRetention only fills the id and retention of the rooms.
+	GetRoomsWithRetention(ctx context.Context) (rooms []Room, err error)
+	CountMessagesBefore(ctx context.Context, roomID string, before time.Time) (count int64, err error)
+	// DeleteMessagesBefore deletes up to limit of the oldest messages created
+	// before the given time, with their receipts, revisions, reactions and the
+	// read cursors on them. Expired roots with replies that are not expired yet
+	// are emptied into tombstones instead, deleted counts both.
+	DeleteMessagesBefore(ctx context.Context, roomID string, before time.Time, limit int) (deleted int64, err error)
+	// SearchPublicRooms leaves archived channels out, total counts every match
+	// regardless of limit and offset.
+	SearchPublicRooms(ctx context.Context, userID string, query string, limit int, offset int) (channels []PublicChannel, total int, err error)
+	GetMessagesAfterID(ctx context.Context, roomID string, messageID string, limit int) (messages []Message, err error)
+	// GetLatestMessageID returns sql.ErrNoRows when the room has no messages.
+	GetLatestMessageID(ctx context.Context, roomID string) (messageID uuid.UUID, err error)
+	// GetMessagesCreatedAfter pages through every room on (created_at, id),
+	// afterID is the last message of the previous page.
+	GetMessagesCreatedAfter(ctx context.Context, createdAfter time.Time, afterID string, limit int) (messages []Message, err error)
+	GetMessageByID(ctx context.Context, messageID string) (message Message, err error)
+	// UpdateMessageContent keeps the current content as a revision before
+	// replacing it. It returns sql.ErrNoRows when the message is deleted or
+	// older than editWindow.
+	UpdateMessageContent(ctx context.Context, messageID string, editorID string, content string, editWindow time.Duration) (message Message, err error)
+	GetMessageRevisions(ctx context.Context, messageID string) (revisions []MessageRevision, err error)
+	// DeleteMessageContent turns the message into a tombstone, its revisions
+	// go away with the content.
+	DeleteMessageContent(ctx context.Context, messageID string, deletedBy string) (message Message, err error)
+	// PurgeMessage deletes the message row and its whole thread, replyIDs
+	// lists the replies that went with it. It returns sql.ErrNoRows when the
+	// message does not exist.
+	PurgeMessage(ctx context.Context, messageID string) (replyIDs []uuid.UUID, err error)
+	// InsertReaction tells with added whether the reaction is new, reacting
+	// twice with the same emoji keeps the first one.
+	InsertReaction(ctx context.Context, messageID string, userID string, emoji string) (reaction MessageReaction, added bool, err error)
+	// DeleteReaction returns sql.ErrNoRows when there was no such reaction.
+	DeleteReaction(ctx context.Context, messageID string, userID string, emoji string) (err error)
+	// GetReactionSummaries groups the reactions of the messages by message id,
+	// Reacted is set for the reactions of the user with the given email.
+	GetReactionSummaries(ctx context.Context, messageIDs []string, userEmail string) (summaries map[string][]ReactionSummary, err error)
+	InsertThreadReply(ctx context.Context, roomID string, parentMessageID string, userID string, content string, replyToMessageID string) (message Message, err error)
+	GetThreadReplies(ctx context.Context, parentMessageID string, limit int, offset int) (messages []Message, err error)
+	// AdvanceThreadReadCursor only moves the cursor forward, like AdvanceReadCursor.
+	AdvanceThreadReadCursor(ctx context.Context, parentMessageID string, userID string, messageID string) (cursor ThreadReadCursor, advanced bool, err error)
+	GetThreadReadCursor(ctx context.Context, parentMessageID string, userID string) (cursor ThreadReadCursor, err error)
+	// CountThreadUnread counts the replies of the others after the user's
+	// thread read cursor.
+	CountThreadUnread(ctx context.Context, parentMessageID string, userID string) (count int, err error)
+	MarkThreadMessagesRead(ctx context.Context, parentMessageID string, userID string, lastReadMessageID string) (messages []Message, err error)
+	GetRoomMatesByEmail(ctx context.Context, userEmail string) (users []User, err error)
+	// AdvanceReadCursor only moves the cursor forward, advanced is false when
+	// the message is not newer than the one already read.
+	AdvanceReadCursor(ctx context.Context, roomID string, userID string, messageID string) (cursor ReadCursor, advanced bool, err error)
+	GetReadCursor(ctx context.Context, roomID string, userID string) (cursor ReadCursor, err error)
+	// MarkMessagesDelivered and MarkMessagesRead return the messages whose
+	// receipt changed, with their aggregated status after the change.
+	MarkMessagesDelivered(ctx context.Context, userID string, messageIDs []string) (messages []Message, err error)
+	// MarkMessagesRead only marks the messages after previousMessageID, the
+	// previous read cursor, and at most the newest 500 of them.
+	MarkMessagesRead(ctx context.Context, roomID string, userID string, previousMessageID string, lastReadMessageID string) (messages []Message, err error)
+	GetMessageReceipts(ctx context.Context, messageID string) (receipts []MessageReceipt, err error)
+}
+
+type IInviteRepository interface {
+	InsertInvite(ctx context.Context, invite RoomInvite) (created RoomInvite, err error)
+	GetInviteByID(ctx context.Context, inviteID string) (invite RoomInvite, err error)
+	GetActiveInvitesByRoomID(ctx context.Context, roomID string) (invites []RoomInvite, err error)
+	// RevokeInvite returns sql.ErrNoRows when the room has no such active invite.
+	RevokeInvite(ctx context.Context, roomID string, inviteID string) (err error)
+	// RedeemInvite adds the user to the room and records the redemption in one
+	// go, it returns sql.ErrNoRows when the invite can no longer be used.
+	// joined is false, and the use not taken, when the user is already in.
+	RedeemInvite(ctx context.Context, inviteID string, userID string) (member RoomMember, joined bool, err error)
+	GetInviteRedemptions(ctx context.Context, inviteID string) (redemptions []InviteRedemption, err error)
+}
+
+type IEventPublisher interface {
+	Publish(ctx context.Context, event Event) (err error)
+}
+
+// IPresenceStore tracks the open realtime connections of every user, shared
+// between API instances. Connections not refreshed within their ttl expire.
+type IPresenceStore interface {
+	AddConnection(ctx context.Context, userID string, connectionID string, ttl time.Duration) (connections int64, err error)
+	RemoveConnection(ctx context.Context, userID string, connectionID string) (connections int64, err error)
+	CountConnections(ctx context.Context, userID string) (connections int64, err error)
+	// PopExpiredUsers returns the users whose last connection expired since
+	// the previous call, each to one caller only.
+	PopExpiredUsers(ctx context.Context) (userIDs []string, err error)
+	SetStatus(ctx context.Context, userID string, status string) (err error)
+	GetStatus(ctx context.Context, userID string) (status string, err error)
+}
+
+type IRateLimiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, err error)
 }
@@ -0,0 +1,34 @@
+package realtime
+
+import (
+	"encoding/json"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/google/uuid"
+)
+
+// Event bus drivers, picked with EVENT_BUS
+const (
+	EventBusMemory   = "memory"
+	EventBusPostgres = "postgres"
+	EventBusRedis    = "redis"
+)
+
+// eventEnvelope is what travels between replicas. Recipients are carried
+// next to the event since they are never serialized for clients. Message
+// events too large for the transport are sent as a reference and loaded back
+// from the messages table.
+type eventEnvelope struct {
+	Event      chat.Event  `json:"event"`
+	Recipients []uuid.UUID `json:"recipients"`
+	MessageID  string      `json:"message_id,omitempty"`
+}
+
+func decodeEventEnvelope(payload []byte) (envelope eventEnvelope, err error) {
+	if err = json.Unmarshal(payload, &envelope); err != nil {
+		return
+	}
+
+	envelope.Event.Recipients = envelope.Recipients
+	return
+}
@@ -0,0 +1,30 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+)
+
+// FanoutEventPublisher hands every event to each of its publishers, a
+// failing publisher does not stop the others.
+type FanoutEventPublisher struct {
+	publishers []chat.IEventPublisher
+}
+
+func NewFanoutEventPublisher(publishers ...chat.IEventPublisher) chat.IEventPublisher {
+	return &FanoutEventPublisher{publishers}
+}
+
+func (fanout *FanoutEventPublisher) Publish(ctx context.Context, event chat.Event) (err error) {
+	var errs []error
+	for _, publisher := range fanout.publishers {
+		if errPublish := publisher.Publish(ctx, event); errPublish != nil {
+			errs = append(errs, errPublish)
+		}
+	}
+
+	err = errors.Join(errs...)
+	return
+}
@@ -0,0 +1,117 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+)
+
+const DefaultSubscriptionBuffer = 64
+
+// Hub keeps track of the realtime subscriptions opened on this instance and
+// delivers published events to the subscriptions of every recipient.
+type Hub struct {
+	mu            sync.RWMutex
+	subscriptions map[uuid.UUID]map[uuid.UUID]*Subscription
+	bufferSize    int
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultSubscriptionBuffer
+	}
+
+	return &Hub{
+		subscriptions: map[uuid.UUID]map[uuid.UUID]*Subscription{},
+		bufferSize:    bufferSize,
+	}
+}
+
+// Subscription is a single connection's view of the hub. Events are buffered
+// per subscription; when the buffer is full the subscription is dropped so a
+// slow client never blocks delivery to the others.
+type Subscription struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+
+	hub    *Hub
+	events chan chat.Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (sub *Subscription) Events() <-chan chat.Event {
+	return sub.events
+}
+
+// Done is closed once the subscription is closed by its owner or dropped by the hub.
+func (sub *Subscription) Done() <-chan struct{} {
+	return sub.done
+}
+
+func (sub *Subscription) Close() {
+	sub.once.Do(func() {
+		sub.hub.remove(sub)
+		close(sub.done)
+	})
+}
+
+func (hub *Hub) Subscribe(userID uuid.UUID) *Subscription {
+	sub := &Subscription{
+		ID:     uuid.New(),
+		UserID: userID,
+		hub:    hub,
+		events: make(chan chat.Event, hub.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.subscriptions[userID] == nil {
+		hub.subscriptions[userID] = map[uuid.UUID]*Subscription{}
+	}
+	hub.subscriptions[userID][sub.ID] = sub
+
+	return sub
+}
+
+// Publish delivers the event to every local subscription of its recipients.
+func (hub *Hub) Publish(ctx context.Context, event chat.Event) (err error) {
+	var targets []*Subscription
+
+	hub.mu.RLock()
+	for _, userID := range event.Recipients {
+		for _, sub := range hub.subscriptions[userID] {
+			targets = append(targets, sub)
+		}
+	}
+	hub.mu.RUnlock()
+
+	for _, sub := range targets {
+		select {
+		case <-sub.done:
+		case sub.events <- event:
+		default:
+			common.Log(common.LOG_LEVEL_WARN, fmt.Sprintf("dropping slow subscription %s of user %s", sub.ID, sub.UserID))
+			sub.Close()
+		}
+	}
+
+	return
+}
+
+func (hub *Hub) remove(sub *Subscription) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	subs := hub.subscriptions[sub.UserID]
+	delete(subs, sub.ID)
+	if len(subs) == 0 {
+		delete(hub.subscriptions, sub.UserID)
+	}
+}
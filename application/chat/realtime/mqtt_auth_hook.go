@@ -0,0 +1,94 @@
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/fikrihkll/chat-app/common/middleware"
+	"github.com/google/uuid"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+const mqttAclTimeout = 5 * time.Second
+
+// RoomMemberChecker reports whether the user with the given email is in the room.
+type RoomMemberChecker func(ctx context.Context, roomID string, userEmail string) (isMember bool, err error)
+
+// MqttAuthHook authenticates broker clients with the API's JWTs, sent as the
+// MQTT password, and only lets them subscribe to the rooms they belong to.
+// The broker checks again before every delivery, so members removed from a
+// room stop receiving it right away. Publishing is reserved to the API's
+// inline client.
+type MqttAuthHook struct {
+	mqtt.HookBase
+	isRoomMember RoomMemberChecker
+	clients      sync.Map
+}
+
+func NewMqttAuthHook(isRoomMember RoomMemberChecker) *MqttAuthHook {
+	return &MqttAuthHook{isRoomMember: isRoomMember}
+}
+
+func (hook *MqttAuthHook) ID() string {
+	return "chat-jwt-auth"
+}
+
+func (hook *MqttAuthHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnConnectAuthenticate,
+		mqtt.OnACLCheck,
+		mqtt.OnDisconnect,
+	}, []byte{b})
+}
+
+func (hook *MqttAuthHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) bool {
+	claims, err := middleware.ParseToken(string(pk.Connect.Password))
+	if err != nil {
+		return false
+	}
+
+	hook.clients.Store(cl.ID, claims)
+	return true
+}
+
+func (hook *MqttAuthHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) bool {
+	if write {
+		return false
+	}
+
+	value, ok := hook.clients.Load(cl.ID)
+	if !ok {
+		return false
+	}
+	claims := value.(middleware.TokenClaims)
+
+	roomID, ok := strings.CutPrefix(topic, "chat/rooms/")
+	if !ok {
+		return false
+	}
+
+	// rejects wildcards and nested topics too
+	if _, err := uuid.Parse(roomID); err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mqttAclTimeout)
+	defer cancel()
+
+	isMember, err := hook.isRoomMember(ctx, roomID, claims.Email)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return false
+	}
+
+	return isMember
+}
+
+func (hook *MqttAuthHook) OnDisconnect(cl *mqtt.Client, err error, expire bool) {
+	hook.clients.Delete(cl.ID)
+}
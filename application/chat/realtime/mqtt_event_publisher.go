@@ -0,0 +1,86 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	mqtt "github.com/mochi-mqtt/server/v2"
+)
+
+const (
+	mqttQos            = 1
+	mqttPublishTimeout = 5 * time.Second
+)
+
+// mqttRoomEvents are the event types published on the room topics, the
+// remaining ones are addressed to specific users and stay off the broker.
+var mqttRoomEvents = map[string]bool{
+	chat.EventMessageCreated:  true,
+	chat.EventMessageRead:     true,
+	chat.EventMessageUpdated:  true,
+	chat.EventMessageDeleted:  true,
+	chat.EventMessagePurged:   true,
+	chat.EventReactionAdded:   true,
+	chat.EventReactionRemoved: true,
+}
+
+func MqttRoomTopic(roomID uuid.UUID) string {
+	return fmt.Sprintf("chat/rooms/%s", roomID)
+}
+
+// MqttEventPublisher publishes room events to chat/rooms/{room_id} on either
+// the embedded broker or an external one.
+type MqttEventPublisher struct {
+	publish func(topic string, payload []byte) error
+}
+
+func NewMqttBrokerEventPublisher(server *mqtt.Server) chat.IEventPublisher {
+	return &MqttEventPublisher{func(topic string, payload []byte) error {
+		return server.Publish(topic, payload, false, mqttQos)
+	}}
+}
+
+func NewMqttClientEventPublisher(client paho.Client) chat.IEventPublisher {
+	return &MqttEventPublisher{func(topic string, payload []byte) error {
+		token := client.Publish(topic, mqttQos, false, payload)
+		if !token.WaitTimeout(mqttPublishTimeout) {
+			return fmt.Errorf("publish to %s timed out", topic)
+		}
+		return token.Error()
+	}}
+}
+
+func (publisher *MqttEventPublisher) Publish(ctx context.Context, event chat.Event) (err error) {
+	if !mqttRoomEvents[event.Type] {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	err = publisher.publish(MqttRoomTopic(event.RoomID), payload)
+	return
+}
+
+// MqttServiceCredentials signs the token the API itself uses to connect to
+// an external broker, which is expected to validate it with the same secret.
+func MqttServiceCredentials(clientID string) paho.CredentialsProvider {
+	return func() (username string, password string) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": clientID,
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		})
+
+		password, _ = token.SignedString([]byte(os.Getenv("SECRET")))
+		return clientID, password
+	}
+}
@@ -0,0 +1,179 @@
+package realtime
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	pgEventChannel = "chat_events"
+	// NOTIFY payloads must stay below 8000 bytes
+	pgNotifyPayloadLimit = 7900
+	pgListenerPingPeriod = 90 * time.Second
+	pgResyncBatchSize    = 500
+)
+
+// PgEventBus fans events out to every replica through Postgres NOTIFY. Each
+// replica LISTENs and hands what it receives to its local publisher, the hub
+// included, so events published here also come back to this instance.
+type PgEventBus struct {
+	db             *sql.DB
+	listener       *pq.Listener
+	local          chat.IEventPublisher
+	chatRepository chat.IChatRepository
+
+	mu            sync.Mutex
+	lastMessageAt time.Time
+}
+
+func NewPgEventBus(db *sql.DB, listener *pq.Listener, local chat.IEventPublisher, chatRepository chat.IChatRepository) *PgEventBus {
+	return &PgEventBus{
+		db:             db,
+		listener:       listener,
+		local:          local,
+		chatRepository: chatRepository,
+	}
+}
+
+func (bus *PgEventBus) Publish(ctx context.Context, event chat.Event) (err error) {
+	envelope := eventEnvelope{Event: event, Recipients: event.Recipients}
+
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return
+	}
+
+	if len(payload) > pgNotifyPayloadLimit {
+		if event.Type != chat.EventMessageCreated {
+			err = fmt.Errorf("event %s is too large to notify", event.ID)
+			return
+		}
+
+		envelope.Event.Data = nil
+		envelope.MessageID = event.ID
+		if payload, err = json.Marshal(envelope); err != nil {
+			return
+		}
+	}
+
+	_, err = bus.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", pgEventChannel, string(payload))
+	return
+}
+
+// Listen delivers notifications to the local publisher until ctx is done.
+// After the listener reconnects, the messages saved meanwhile are replayed;
+// ephemeral events sent during the outage are lost.
+func (bus *PgEventBus) Listen(ctx context.Context) (err error) {
+	if err = bus.db.QueryRowContext(ctx, "SELECT LOCALTIMESTAMP").Scan(&bus.lastMessageAt); err != nil {
+		return
+	}
+
+	if err = bus.listener.Listen(pgEventChannel); err != nil {
+		return
+	}
+	defer bus.listener.Close()
+
+	ticker := time.NewTicker(pgListenerPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-bus.listener.Notify:
+			if notification == nil {
+				bus.resync(ctx)
+				continue
+			}
+			bus.dispatch(ctx, notification.Extra)
+		case <-ticker.C:
+			go bus.listener.Ping()
+		}
+	}
+}
+
+func (bus *PgEventBus) dispatch(ctx context.Context, payload string) {
+	envelope, err := decodeEventEnvelope([]byte(payload))
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	event := envelope.Event
+
+	if envelope.MessageID != "" {
+		message, err := bus.chatRepository.GetMessageByID(ctx, envelope.MessageID)
+		if err != nil {
+			return
+		}
+		event.Data = message
+	}
+
+	if event.Type == chat.EventMessageCreated {
+		bus.seen(event.CreatedAt)
+	}
+
+	if err := bus.local.Publish(ctx, event); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
+
+func (bus *PgEventBus) seen(createdAt time.Time) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if createdAt.After(bus.lastMessageAt) {
+		bus.lastMessageAt = createdAt
+	}
+}
+
+// resync replays the messages saved after the last one this replica received.
+func (bus *PgEventBus) resync(ctx context.Context) {
+	bus.mu.Lock()
+	since := bus.lastMessageAt
+	bus.mu.Unlock()
+
+	members := map[uuid.UUID][]uuid.UUID{}
+	afterID := ""
+
+	for {
+		messages, err := bus.chatRepository.GetMessagesCreatedAfter(ctx, since, afterID, pgResyncBatchSize)
+		if err != nil {
+			return
+		}
+
+		for _, message := range messages {
+			recipients, ok := members[message.RoomID]
+			if !ok {
+				users, err := bus.chatRepository.GetRoomMembers(ctx, message.RoomID.String())
+				if err != nil {
+					return
+				}
+				for _, user := range users {
+					recipients = append(recipients, user.ID)
+				}
+				members[message.RoomID] = recipients
+			}
+
+			if err := bus.local.Publish(ctx, chat.NewMessageCreatedEvent(message, recipients)); err != nil {
+				common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			}
+			bus.seen(message.CreatedAt)
+			since = message.CreatedAt
+			afterID = message.ID.String()
+		}
+
+		if len(messages) < pgResyncBatchSize {
+			return
+		}
+	}
+}
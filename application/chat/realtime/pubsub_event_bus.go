@@ -0,0 +1,57 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/fikrihkll/chat-app/infrastructure"
+)
+
+const pubSubEventChannel = "chat:events"
+
+// PubSubEventBus fans events out to every replica through a shared PubSub,
+// Redis in production. Like PgEventBus, events published here come back to
+// this instance through its own subscription. Events sent while a replica is
+// disconnected from Redis are not replayed.
+type PubSubEventBus struct {
+	pubsub infrastructure.PubSub
+	local  chat.IEventPublisher
+}
+
+func NewPubSubEventBus(pubsub infrastructure.PubSub, local chat.IEventPublisher) *PubSubEventBus {
+	return &PubSubEventBus{pubsub, local}
+}
+
+func (bus *PubSubEventBus) Publish(ctx context.Context, event chat.Event) (err error) {
+	payload, err := json.Marshal(eventEnvelope{Event: event, Recipients: event.Recipients})
+	if err != nil {
+		return
+	}
+
+	err = bus.pubsub.Publish(ctx, pubSubEventChannel, payload)
+	return
+}
+
+// Listen delivers events to the local publisher until ctx is done.
+func (bus *PubSubEventBus) Listen(ctx context.Context) (err error) {
+	payloads, err := bus.pubsub.Subscribe(ctx, pubSubEventChannel)
+	if err != nil {
+		return
+	}
+
+	for payload := range payloads {
+		envelope, err := decodeEventEnvelope(payload)
+		if err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			continue
+		}
+
+		if err := bus.local.Publish(ctx, envelope.Event); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		}
+	}
+
+	return
+}
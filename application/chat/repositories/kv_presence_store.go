@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/fikrihkll/chat-app/infrastructure"
+)
+
+type PresenceStoreKeyValue struct {
+	store infrastructure.KeyValueStore
+}
+
+func NewPresenceStoreKeyValue(store infrastructure.KeyValueStore) chat.IPresenceStore {
+	return &PresenceStoreKeyValue{store}
+}
+
+func connectionsKey(userID string) string {
+	return "presence:connections:" + userID
+}
+
+func statusKey(userID string) string {
+	return "presence:status:" + userID
+}
+
+// expiryKey schedules every connected user for when their last connection
+// expires without a heartbeat.
+const expiryKey = "presence:expiry"
+
+func (repo *PresenceStoreKeyValue) AddConnection(ctx context.Context, userID string, connectionID string, ttl time.Duration) (connections int64, err error) {
+	expiresAt := time.Now().Add(ttl)
+
+	connections, err = repo.store.AddToSet(ctx, connectionsKey(userID), connectionID, expiresAt)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = repo.store.Schedule(ctx, expiryKey, userID, expiresAt); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+	return
+}
+
+func (repo *PresenceStoreKeyValue) RemoveConnection(ctx context.Context, userID string, connectionID string) (connections int64, err error) {
+	connections, err = repo.store.RemoveFromSet(ctx, connectionsKey(userID), connectionID)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if connections == 0 {
+		if err = repo.store.Unschedule(ctx, expiryKey, userID); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		}
+	}
+	return
+}
+
+func (repo *PresenceStoreKeyValue) PopExpiredUsers(ctx context.Context) (userIDs []string, err error) {
+	due, err := repo.store.PopDue(ctx, expiryKey, time.Now())
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	for _, userID := range due {
+		// a heartbeat can land between the pop and this count
+		connections, errCount := repo.CountConnections(ctx, userID)
+		if errCount != nil {
+			err = errCount
+			return
+		}
+		if connections == 0 {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	return
+}
+
+func (repo *PresenceStoreKeyValue) CountConnections(ctx context.Context, userID string) (connections int64, err error) {
+	connections, err = repo.store.CountSet(ctx, connectionsKey(userID))
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+	return
+}
+
+func (repo *PresenceStoreKeyValue) SetStatus(ctx context.Context, userID string, status string) (err error) {
+	if err = repo.store.Set(ctx, statusKey(userID), status, 0); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+	return
+}
+
+func (repo *PresenceStoreKeyValue) GetStatus(ctx context.Context, userID string) (status string, err error) {
+	status, err = repo.store.Get(ctx, statusKey(userID))
+	if errors.Is(err, infrastructure.ErrKeyNotFound) {
+		err = nil
+		return
+	}
+
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+	return
+}
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/fikrihkll/chat-app/infrastructure"
+)
+
+// RateLimiterKeyValue counts hits in fixed windows on the shared store, so the
+// limit holds across API instances.
+type RateLimiterKeyValue struct {
+	store infrastructure.KeyValueStore
+}
+
+func NewRateLimiterKeyValue(store infrastructure.KeyValueStore) chat.IRateLimiter {
+	return &RateLimiterKeyValue{store}
+}
+
+func (limiter *RateLimiterKeyValue) Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, err error) {
+	windowKey := fmt.Sprintf("ratelimit:%s:%d", key, time.Now().UnixNano()/int64(window))
+
+	count, err := limiter.store.Incr(ctx, windowKey, window)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	allowed = count <= int64(limit)
+	return
+}
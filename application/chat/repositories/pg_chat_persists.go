@@ -4,11 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"strings"
 	"time"
 
 	"github.com/fikrihkll/chat-app/application/chat"
 	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
@@ -22,69 +23,127 @@ func NewChatRepositoryPostgree(db *sql.DB) chat.IChatRepository {
 
 var ErrRoomNotFound = errors.New("room not found")
 
-func (repo *ChatRepositoryPostgree) InsertMessageByEmail(ctx context.Context, newMessage chat.NewMessageByEmailParam, targetUser chat.User) (err error) {
+// messageColumns selects a message aliased m together with its aggregated
+// status: read or delivered once every other room member read or received it.
+const messageColumns = `m.id, m.user_id, m.room_id, m.content, m.kind,
+	(SELECT CASE
+		WHEN COUNT(su.id) > 0 AND COUNT(su.id) = COUNT(mr.read_at) THEN 'read'
+		WHEN COUNT(su.id) > 0 AND COUNT(su.id) = COUNT(mr.user_id) THEN 'delivered'
+		ELSE 'sent' END
+	FROM room_members su
+	LEFT JOIN message_receipts mr ON mr.message_id = m.id AND mr.user_id = su.user_id
+	WHERE su.room_id = m.room_id AND su.user_id <> m.user_id),
+	m.edited, m.created_at, m.updated_at, m.deleted_at, m.deleted_by, m.parent_message_id,
+	(SELECT COUNT(*) FROM messages t WHERE t.parent_message_id = m.id),
+	(SELECT MAX(t.created_at) FROM messages t WHERE t.parent_message_id = m.id),
+	m.reply_to_message_id,
+	(SELECT q.user_id FROM messages q WHERE q.id = m.reply_to_message_id AND q.deleted_at IS NULL),
+	(SELECT LEFT(q.content, 140) FROM messages q WHERE q.id = m.reply_to_message_id AND q.deleted_at IS NULL)`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage reads a row selected with messageColumns. A quote whose
+// original is gone reads as deleted.
+func scanMessage(row rowScanner, message *chat.Message) (err error) {
+	var replyToID, quotedUserID uuid.NullUUID
+	var quotedExcerpt sql.NullString
+
+	err = row.Scan(
+		&message.ID, &message.UserID, &message.RoomID, &message.Content, &message.Kind, &message.Status, &message.Edited, &message.CreatedAt, &message.UpdatedAt,
+		&message.DeletedAt, &message.DeletedBy, &message.ParentMessageID, &message.ReplyCount, &message.LastReplyAt,
+		&replyToID, &quotedUserID, &quotedExcerpt,
+	)
+	if err != nil || !replyToID.Valid {
+		return
+	}
+
+	message.ReplyTo = &chat.QuotedMessage{ID: replyToID.UUID, Excerpt: chat.QuoteDeletedExcerpt, Deleted: true}
+	if quotedUserID.Valid {
+		message.ReplyTo.UserID = &quotedUserID.UUID
+		message.ReplyTo.Excerpt = quotedExcerpt.String
+		message.ReplyTo.Deleted = false
+	}
+
+	return
+}
+
+// roomColumns selects a room aliased r, with the member emails clients know
+// as Room.Users.
+const roomColumns = `r.id, r.name, r.topic, r.description, r.avatar_url, r.archived_at, r.kind, r.visibility, r.retention_days,
+	ARRAY(SELECT ru.email FROM room_members rm JOIN users ru ON ru.id = rm.user_id
+		WHERE rm.room_id = r.id ORDER BY rm.joined_at, ru.email),
+	r.created_at, r.updated_at`
+
+// notificationColumns selects the notification settings of a member aliased
+// me, a mute that ran out reads as not muted.
+const notificationColumns = `me.notification_level,
+	me.muted AND (me.muted_until IS NULL OR me.muted_until > NOW()),
+	CASE WHEN me.muted AND me.muted_until > NOW() THEN me.muted_until END`
+
+const insertMessageSql = `INSERT INTO messages (user_id, room_id, content, reply_to_message_id) VALUES($1, $2, $3, $4)
+	RETURNING id`
+
+// directRoomSql finds the direct room of the users with the given emails
+// through its pair key, group rooms holding both of them do not count.
+const directRoomSql = `SELECT r.id FROM rooms r
+	WHERE r.kind = 'direct' AND r.dm_key = (
+		SELECT string_agg(u.id::text, ':' ORDER BY u.id) FROM users u WHERE u.email IN ($1, $2)
+	)`
+
+func (repo *ChatRepositoryPostgree) InsertMessageByEmail(ctx context.Context, newMessage chat.NewMessageByEmailParam, targetUser chat.User) (message chat.Message, err error) {
 	tx, err := repo.db.Begin()
 	if err != nil {
 		common.Log(common.LOG_LEVEL_ERROR, err.Error())
 		return
 	}
 
-	roomSql := "SELECT * FROM rooms WHERE users @> $1"
-	roomRow := tx.QueryRowContext(ctx, roomSql, pq.Array([]string{newMessage.MemberEmail, newMessage.CurrentUserEmail}))
-	if roomRow.Err() != nil {
-		err = roomRow.Err()
-		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	currentUserID, err := uuid.Parse(newMessage.CurrentUserID)
+	if err != nil {
+		tx.Rollback()
 		return
 	}
 
-	var room chat.Room
-	if err = roomRow.Scan(&room.ID, &room.Name, pq.Array(&room.Users), &room.CreatedAt, &room.UpdatedAt); err != nil {
-		if err != sql.ErrNoRows {
-			common.Log(common.LOG_LEVEL_ERROR, err.Error())
-			return
-		} 
-		
-		if err == sql.ErrNoRows {
-			insertRoomSql := "INSERT INTO rooms VALUES(uuid_generate_v4(), $1, $2, NOW(), NOW()) RETURNING id"
-			
-			var lastInsertedID string
-
-			errRoom := tx.QueryRow(insertRoomSql, "room", pq.Array([]string{newMessage.CurrentUserEmail, targetUser.Email})).Scan(&lastInsertedID)
-			if errRoom != nil {
-				err = errRoom
-				tx.Rollback()
-				log.Fatalf("Failed to insert room: %v", err)
-				common.Log(common.LOG_LEVEL_ERROR, err.Error())
-				return
-			}
+	// the unique pair key makes concurrent first messages end up in the same
+	// room, the loser of the race waits for the winner and gets its room back
+	upsertRoomSql := `INSERT INTO rooms (name, kind, dm_key) VALUES('room', 'direct', $1)
+		ON CONFLICT (dm_key) DO UPDATE SET dm_key = EXCLUDED.dm_key
+		RETURNING id`
 
-			err = tx.QueryRowContext(ctx, "SELECT * FROM rooms WHERE id = $1", lastInsertedID).Scan(&room.ID, &room.Name, pq.Array(&room.Users), &room.CreatedAt, &room.CreatedAt)
-			if err != nil {
-				tx.Rollback()
-				common.Log(common.LOG_LEVEL_ERROR, err.Error())
-				return 
-			}
-		}
+	var room chat.Room
+	if err = tx.QueryRowContext(ctx, upsertRoomSql, chat.DirectRoomKey(currentUserID, targetUser.ID)).Scan(&room.ID); err != nil {
+		tx.Rollback()
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
 	}
 
-	insertMessageSql := "INSERT INTO messages VALUES(uuid_generate_v4(), $1, $2, $3, NOW(), NOW())"
-	stmtMessage, err := tx.Prepare(insertMessageSql)
-	if err != nil {
+	// a member who left the direct room is back with the next message
+	insertMemberSql := `INSERT INTO room_members (room_id, user_id) VALUES($1, $2), ($1, $3)
+		ON CONFLICT (room_id, user_id) DO NOTHING`
+
+	if _, err = tx.ExecContext(ctx, insertMemberSql, room.ID, currentUserID, targetUser.ID); err != nil {
 		tx.Rollback()
 		common.Log(common.LOG_LEVEL_ERROR, err.Error())
 		return
 	}
-	defer stmtMessage.Close()
 
-	_, errMessage := stmtMessage.Exec(newMessage.CurrentUserID, room.ID, newMessage.Message)
+	var messageID string
+	errMessage := tx.QueryRowContext(ctx, insertMessageSql, newMessage.CurrentUserID, room.ID, newMessage.Message, nullString(newMessage.ReplyToMessageID)).Scan(&messageID)
 	if errMessage != nil {
 		tx.Rollback()
-		log.Fatalf("Failed to prepare statement: %v", err)
 		err = errMessage
 		common.Log(common.LOG_LEVEL_ERROR, err.Error())
 		return
 	}
 
+	// read back for the quote snapshot
+	if err = scanMessage(tx.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages m WHERE m.id = $1", messageID), &message); err != nil {
+		tx.Rollback()
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
 	if err = tx.Commit(); err != nil {
 		common.Log(common.LOG_LEVEL_ERROR, err.Error())
 		return
@@ -93,76 +152,452 @@ func (repo *ChatRepositoryPostgree) InsertMessageByEmail(ctx context.Context, ne
 	return
 }
 
-func (repo *ChatRepositoryPostgree) InsertMessageByRoomID(ctx context.Context, newMessage chat.NewMessageByRoomIDParam) (err error) {
-	var lastInsertedID string
-	
-	insertMessageSql := "INSERT INTO messages VALUES(uuid_generate_v4(), $1, $2, $3, NOW(), NOW()) RETURNING id"
-	err = repo.db.QueryRowContext(ctx, insertMessageSql, newMessage.CurrentUserID, newMessage.RoomID, newMessage.Message).Scan(&lastInsertedID)
+func (repo *ChatRepositoryPostgree) InsertMessageByRoomID(ctx context.Context, newMessage chat.NewMessageByRoomIDParam) (message chat.Message, err error) {
+	var messageID string
+	err = repo.db.QueryRowContext(ctx, insertMessageSql, newMessage.CurrentUserID, newMessage.RoomID, newMessage.Message, nullString(newMessage.ReplyToMessageID)).Scan(&messageID)
 	if err != nil {
 		common.Log(common.LOG_LEVEL_ERROR, err.Error())
 		return
 	}
 
+	// read back for the quote snapshot
+	message, err = repo.GetMessageByID(ctx, messageID)
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetDirectRoomID(ctx context.Context, currentUserEmail string, targetEmail string) (roomID string, err error) {
+	err = repo.db.QueryRowContext(ctx, directRoomSql, targetEmail, currentUserEmail).Scan(&roomID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		}
+		err = ErrRoomNotFound
+	}
+
 	return
 }
 
 func (repo *ChatRepositoryPostgree) GetMessage(ctx context.Context, param chat.MessageHistoryParams) (messages []chat.Message, err error) {
 	timeAfterDt := time.UnixMilli(param.TimeAfter)
 
-	sqlRoom := "SELECT id FROM rooms WHERE users @> $1"
+	roomID := param.RoomID
+	if roomID == "" {
+		err = ErrRoomNotFound
+		return
+	}
+
+	// thread replies are read through their thread
+	sqlMessage := "SELECT " + messageColumns + " FROM messages m WHERE m.room_id = $1 AND m.parent_message_id IS NULL AND m.created_at > $2 ORDER BY m.created_at"
 
-	row, err := repo.db.QueryContext(ctx, sqlRoom, pq.Array([]string{param.TargetEmail, param.CurrentUserEmail}))
+	rows, err := repo.db.QueryContext(ctx, sqlMessage, roomID, timeAfterDt)
 	if err != nil {
 		common.Log(common.LOG_LEVEL_ERROR, err.Error())
-		common.Log(common.LOG_LEVEL_ERROR, ErrRoomNotFound.Error())
-		err = ErrRoomNotFound
 		return
 	}
 
-	var roomID string
+	for rows.Next() {
+		var message chat.Message
+
+		if err = scanMessage(rows, &message); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+
+		messages = append(messages, message)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetRoomsByID(ctx context.Context, currentUserEmail string) (rooms []chat.Room, err error) {
+	// unread counts only messages from the others that came after the read
+	// cursor, thread replies have their own read state
+	sqlRoom := `SELECT ` + roomColumns + `,
+			(SELECT COUNT(*) FROM messages m
+				WHERE m.room_id = r.id AND m.user_id <> u.id AND m.parent_message_id IS NULL
+					AND (lm.id IS NULL OR (m.created_at, m.id) > (lm.created_at, lm.id))),
+			` + notificationColumns + `,
+			lm.id, lm.user_id, lm.room_id, lm.content, lm.kind, lm.edited, lm.created_at, lm.updated_at, lm.deleted_at, lm.deleted_by
+		FROM users u
+		JOIN room_members me ON me.user_id = u.id
+		JOIN rooms r ON r.id = me.room_id
+		LEFT JOIN room_read_cursors c ON c.room_id = r.id AND c.user_id = u.id
+		LEFT JOIN messages lm ON lm.id = c.message_id
+		WHERE u.email = $1`
+
+	row, err := repo.db.QueryContext(ctx, sqlRoom, currentUserEmail)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		err = ErrRoomNotFound
+		return
+	}
+	defer row.Close()
 
 	for row.Next() {
-		if err = row.Scan(&roomID); err != nil {
+		var room chat.Room
+		var notifications chat.NotificationSettings
+		var lastReadID, lastReadUserID, lastReadRoomID uuid.NullUUID
+		var lastReadContent, lastReadKind sql.NullString
+		var lastReadEdited sql.NullBool
+		var lastReadCreatedAt, lastReadUpdatedAt sql.NullTime
+		var lastReadDeletedAt *time.Time
+		var lastReadDeletedBy *uuid.UUID
+
+		if err = row.Scan(
+			&room.ID, &room.Name, &room.Topic, &room.Description, &room.AvatarURL, &room.ArchivedAt, &room.Kind, &room.Visibility, &room.RetentionDays, pq.Array(&room.Users), &room.CreatedAt, &room.UpdatedAt,
+			&room.UnreadCount,
+			&notifications.Level, &notifications.Muted, &notifications.MutedUntil,
+			&lastReadID, &lastReadUserID, &lastReadRoomID, &lastReadContent, &lastReadKind, &lastReadEdited, &lastReadCreatedAt, &lastReadUpdatedAt, &lastReadDeletedAt, &lastReadDeletedBy,
+		); err != nil {
 			common.Log(common.LOG_LEVEL_ERROR, err.Error())
 			return
 		}
+
+		room.Notifications = &notifications
+
+		if lastReadID.Valid {
+			room.LastReadMessage = &chat.Message{
+				ID:        lastReadID.UUID,
+				UserID:    lastReadUserID.UUID,
+				RoomID:    lastReadRoomID.UUID,
+				Content:   lastReadContent.String,
+				Kind:      lastReadKind.String,
+				Edited:    lastReadEdited.Bool,
+				CreatedAt: lastReadCreatedAt.Time,
+				UpdatedAt: lastReadUpdatedAt.Time,
+				DeletedAt: lastReadDeletedAt,
+				DeletedBy: lastReadDeletedBy,
+			}
+		}
+
+		rooms = append(rooms, room)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) InsertRoom(ctx context.Context, name string, visibility string, ownerEmail string, memberEmails []string) (room chat.Room, err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	insertRoomSql := "INSERT INTO rooms (name, visibility) VALUES($1, $2) RETURNING id"
+
+	if err = tx.QueryRowContext(ctx, insertRoomSql, name, visibility).Scan(&room.ID); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	insertMemberSql := `INSERT INTO room_members (room_id, user_id, role)
+		SELECT $1, id, CASE WHEN email = $3 THEN 'owner' ELSE 'member' END
+		FROM users WHERE email = ANY($2) OR email = $3`
+
+	if _, err = tx.ExecContext(ctx, insertMemberSql, room.ID, pq.Array(memberEmails), ownerEmail); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT "+roomColumns+" FROM rooms r WHERE r.id = $1", room.ID).Scan(
+		&room.ID, &room.Name, &room.Topic, &room.Description, &room.AvatarURL, &room.ArchivedAt, &room.Kind, &room.Visibility, &room.RetentionDays, pq.Array(&room.Users), &room.CreatedAt, &room.UpdatedAt,
+	)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetRoomByID(ctx context.Context, roomID string) (room chat.Room, err error) {
+	err = repo.db.QueryRowContext(ctx, "SELECT "+roomColumns+" FROM rooms r WHERE r.id = $1", roomID).Scan(
+		&room.ID, &room.Name, &room.Topic, &room.Description, &room.AvatarURL, &room.ArchivedAt, &room.Kind, &room.Visibility, &room.RetentionDays, pq.Array(&room.Users), &room.CreatedAt, &room.UpdatedAt,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) UpdateRoom(ctx context.Context, room chat.Room) (updated chat.Room, err error) {
+	updateRoomSql := `UPDATE rooms r
+		SET name = $2, topic = $3, description = $4, avatar_url = $5, archived_at = $6, visibility = $7, retention_days = $8, updated_at = NOW()
+		WHERE r.id = $1
+		RETURNING ` + roomColumns
+
+	err = repo.db.QueryRowContext(ctx, updateRoomSql, room.ID, room.Name, room.Topic, room.Description, room.AvatarURL, room.ArchivedAt, room.Visibility, room.RetentionDays).Scan(
+		&updated.ID, &updated.Name, &updated.Topic, &updated.Description, &updated.AvatarURL, &updated.ArchivedAt, &updated.Kind, &updated.Visibility, &updated.RetentionDays, pq.Array(&updated.Users), &updated.CreatedAt, &updated.UpdatedAt,
+	)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) InsertSystemMessage(ctx context.Context, roomID string, userID string, content string) (message chat.Message, err error) {
+	insertMessageSql := `INSERT INTO messages (user_id, room_id, content, kind) VALUES($1, $2, $3, 'system')
+		RETURNING id, user_id, room_id, content, kind, created_at, updated_at`
+
+	err = repo.db.QueryRowContext(ctx, insertMessageSql, userID, roomID, content).Scan(
+		&message.ID, &message.UserID, &message.RoomID, &message.Content, &message.Kind, &message.CreatedAt, &message.UpdatedAt,
+	)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
 	}
+	message.Status = chat.MessageStatusSent
+
+	return
+}
 
-	sqlMessage := "SELECT * FROM messages WHERE created_at > $1"
+func (repo *ChatRepositoryPostgree) GetRoomMembers(ctx context.Context, roomID string) (members []chat.User, err error) {
+	sqlMember := `SELECT u.id, u.name, u.email, u.created_at, u.updated_at
+		FROM room_members m JOIN users u ON u.id = m.user_id
+		WHERE m.room_id = $1`
 
-	rows, err := repo.db.QueryContext(ctx, sqlMessage, timeAfterDt)
+	rows, err := repo.db.QueryContext(ctx, sqlMember, roomID)
 	if err != nil {
 		common.Log(common.LOG_LEVEL_ERROR, err.Error())
 		return
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var message chat.Message
+		var member chat.User
+		if err = rows.Scan(&member.ID, &member.Name, &member.Email, &member.CreatedAt, &member.UpdatedAt); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		members = append(members, member)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) IsRoomMember(ctx context.Context, roomID string, userEmail string) (isMember bool, err error) {
+	sqlMember := `SELECT EXISTS(
+		SELECT 1 FROM room_members m JOIN users u ON u.id = m.user_id
+		WHERE m.room_id = $1 AND u.email = $2
+	)`
+
+	if err = repo.db.QueryRowContext(ctx, sqlMember, roomID, userEmail).Scan(&isMember); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetRoomMember(ctx context.Context, roomID string, userEmail string) (member chat.RoomMember, err error) {
+	sqlMember := `SELECT m.room_id, m.user_id, u.name, u.email, m.role, m.joined_at
+		FROM room_members m JOIN users u ON u.id = m.user_id
+		WHERE m.room_id = $1 AND u.email = $2`
+
+	err = repo.db.QueryRowContext(ctx, sqlMember, roomID, userEmail).Scan(
+		&member.RoomID, &member.UserID, &member.Name, &member.Email, &member.Role, &member.JoinedAt,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetRoomMemberships(ctx context.Context, roomID string) (members []chat.RoomMember, err error) {
+	sqlMember := `SELECT m.room_id, m.user_id, u.name, u.email, m.role, m.joined_at
+		FROM room_members m JOIN users u ON u.id = m.user_id
+		WHERE m.room_id = $1
+		ORDER BY m.joined_at, u.email`
+
+	rows, err := repo.db.QueryContext(ctx, sqlMember, roomID)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
 
-		if err = rows.Scan(&message.ID, &message.UserID, &message.RoomID, &message.Content, &message.CreatedAt, &message.UpdatedAt); err != nil {
+	for rows.Next() {
+		var member chat.RoomMember
+		if err = rows.Scan(&member.RoomID, &member.UserID, &member.Name, &member.Email, &member.Role, &member.JoinedAt); err != nil {
 			common.Log(common.LOG_LEVEL_ERROR, err.Error())
 			return
 		}
+		members = append(members, member)
+	}
 
-		messages = append(messages, message)
+	return
+}
+
+func (repo *ChatRepositoryPostgree) UpdateMemberRole(ctx context.Context, roomID string, userID string, role string) (member chat.RoomMember, err error) {
+	sqlMember := `UPDATE room_members m SET role = $3
+		FROM users u
+		WHERE u.id = m.user_id AND m.room_id = $1 AND m.user_id = $2
+		RETURNING m.room_id, m.user_id, u.name, u.email, m.role, m.joined_at`
+
+	err = repo.db.QueryRowContext(ctx, sqlMember, roomID, userID, role).Scan(
+		&member.RoomID, &member.UserID, &member.Name, &member.Email, &member.Role, &member.JoinedAt,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
 	}
 
 	return
 }
 
-func (repo *ChatRepositoryPostgree) GetRoomsByID(ctx context.Context, currentUserEmail string) (rooms []chat.Room, err error) {
-	sqlRoom := "SELECT * FROM rooms WHERE users && $1"
+func (repo *ChatRepositoryPostgree) UpdateNotificationSettings(ctx context.Context, roomID string, userID string, level *string, muted *bool, mutedUntil *time.Time) (settings chat.NotificationSettings, err error) {
+	sqlMember := `UPDATE room_members me
+		SET notification_level = COALESCE($3::varchar, me.notification_level),
+			muted = COALESCE($4::boolean, me.muted),
+			muted_until = CASE WHEN $4::boolean IS NULL THEN me.muted_until ELSE $5::timestamp END
+		WHERE me.room_id = $1 AND me.user_id = $2
+		RETURNING ` + notificationColumns
+
+	err = repo.db.QueryRowContext(ctx, sqlMember, roomID, userID, level, muted, mutedUntil).Scan(
+		&settings.Level, &settings.Muted, &settings.MutedUntil,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
 
-	row, err := repo.db.QueryContext(ctx, sqlRoom, pq.Array([]string{currentUserEmail}))
+func (repo *ChatRepositoryPostgree) InsertRoomMember(ctx context.Context, roomID string, userID string) (member chat.RoomMember, err error) {
+	insertMemberSql := `WITH inserted AS (
+			INSERT INTO room_members (room_id, user_id, role) VALUES($1, $2, 'member')
+			RETURNING room_id, user_id, role, joined_at
+		)
+		SELECT i.room_id, i.user_id, u.name, u.email, i.role, i.joined_at
+		FROM inserted i JOIN users u ON u.id = i.user_id`
+
+	err = repo.db.QueryRowContext(ctx, insertMemberSql, roomID, userID).Scan(
+		&member.RoomID, &member.UserID, &member.Name, &member.Email, &member.Role, &member.JoinedAt,
+	)
 	if err != nil {
 		common.Log(common.LOG_LEVEL_ERROR, err.Error())
-		err = ErrRoomNotFound
 		return
 	}
 
-	for row.Next() {
+	return
+}
+
+func (repo *ChatRepositoryPostgree) DeleteRoomMember(ctx context.Context, roomID string, userID string) (err error) {
+	sqlMember := "DELETE FROM room_members WHERE room_id = $1 AND user_id = $2 RETURNING user_id"
+
+	var deletedID string
+	err = repo.db.QueryRowContext(ctx, sqlMember, roomID, userID).Scan(&deletedID)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) InsertRoomBan(ctx context.Context, ban chat.RoomBan) (created chat.RoomBan, err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	insertBanSql := `INSERT INTO room_bans (room_id, user_id, banned_by, reason) VALUES($1, $2, $3, $4)
+		ON CONFLICT (room_id, user_id) DO UPDATE SET banned_by = EXCLUDED.banned_by, reason = EXCLUDED.reason
+		RETURNING room_id, user_id, banned_by, reason, created_at`
+
+	err = tx.QueryRowContext(ctx, insertBanSql, ban.RoomID, ban.UserID, ban.BannedBy, ban.Reason).Scan(
+		&created.RoomID, &created.UserID, &created.BannedBy, &created.Reason, &created.CreatedAt,
+	)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM room_members WHERE room_id = $1 AND user_id = $2", ban.RoomID, ban.UserID); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = tx.QueryRowContext(ctx, "SELECT name, email FROM users WHERE id = $1", ban.UserID).Scan(&created.Name, &created.Email); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) DeleteRoomBan(ctx context.Context, roomID string, userID string) (err error) {
+	sqlBan := "DELETE FROM room_bans WHERE room_id = $1 AND user_id = $2 RETURNING user_id"
+
+	var deletedID string
+	err = repo.db.QueryRowContext(ctx, sqlBan, roomID, userID).Scan(&deletedID)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetRoomBans(ctx context.Context, roomID string) (bans []chat.RoomBan, err error) {
+	sqlBan := `SELECT b.room_id, b.user_id, u.name, u.email, b.banned_by, b.reason, b.created_at
+		FROM room_bans b JOIN users u ON u.id = b.user_id
+		WHERE b.room_id = $1
+		ORDER BY b.created_at DESC`
+
+	rows, err := repo.db.QueryContext(ctx, sqlBan, roomID)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ban chat.RoomBan
+		if err = rows.Scan(&ban.RoomID, &ban.UserID, &ban.Name, &ban.Email, &ban.BannedBy, &ban.Reason, &ban.CreatedAt); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		bans = append(bans, ban)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) IsBannedFromRoom(ctx context.Context, roomID string, userID string) (isBanned bool, err error) {
+	sqlBan := "SELECT EXISTS(SELECT 1 FROM room_bans WHERE room_id = $1 AND user_id = $2)"
+
+	if err = repo.db.QueryRowContext(ctx, sqlBan, roomID, userID).Scan(&isBanned); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetRoomsWithRetention(ctx context.Context) (rooms []chat.Room, err error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT id, retention_days FROM rooms WHERE retention_days IS NOT NULL")
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
 		var room chat.Room
-		if err = row.Scan(&room.ID, &room.Name, pq.Array(&room.Users), &room.CreatedAt, &room.UpdatedAt); err != nil {
+		if err = rows.Scan(&room.ID, &room.RetentionDays); err != nil {
 			common.Log(common.LOG_LEVEL_ERROR, err.Error())
 			return
 		}
@@ -170,4 +605,813 @@ func (repo *ChatRepositoryPostgree) GetRoomsByID(ctx context.Context, currentUse
 	}
 
 	return
-}
\ No newline at end of file
+}
+
+func (repo *ChatRepositoryPostgree) CountMessagesBefore(ctx context.Context, roomID string, before time.Time) (count int64, err error) {
+	// roots already emptied for replies that are still kept are left alone
+	sqlMessage := `SELECT COUNT(*) FROM messages m
+		WHERE m.room_id = $1 AND m.created_at < $2
+			AND NOT (m.deleted_at IS NOT NULL AND EXISTS (` + keptReplySql + `))`
+
+	if err = repo.db.QueryRowContext(ctx, sqlMessage, roomID, before).Scan(&count); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) DeleteMessagesBefore(ctx context.Context, roomID string, before time.Time, limit int) (deleted int64, err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	// rows another sweeper already holds are left for the next batch, roots
+	// with replies that are still kept are emptied below instead
+	sqlExpired := `SELECT m.id FROM messages m
+		WHERE m.room_id = $1 AND m.created_at < $2
+			AND NOT EXISTS (` + keptReplySql + `)
+		ORDER BY m.created_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, sqlExpired, roomID, before, limit)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	var messageIDs []string
+	for rows.Next() {
+		var messageID string
+		if err = rows.Scan(&messageID); err != nil {
+			rows.Close()
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+	rows.Close()
+
+	if len(messageIDs) > 0 {
+		if deleted, _, err = deleteMessageRows(ctx, tx, messageIDs); err != nil {
+			return
+		}
+	}
+
+	if remaining := limit - len(messageIDs); remaining > 0 {
+		emptied, errEmpty := emptyExpiredRoots(ctx, tx, roomID, before, remaining)
+		if errEmpty != nil {
+			err = errEmpty
+			return
+		}
+		deleted += emptied
+	}
+
+	if err = tx.Commit(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+// keptReplySql finds a reply of the message aliased m that is not expired
+// yet, with $2 the retention limit.
+const keptReplySql = `SELECT 1 FROM messages kept WHERE kept.parent_message_id = m.id AND kept.created_at >= $2`
+
+// emptyExpiredRoots turns expired roots whose thread still has replies to
+// keep into tombstones without a deleted_by, the replies keep their thread.
+func emptyExpiredRoots(ctx context.Context, tx *sql.Tx, roomID string, before time.Time, limit int) (emptied int64, err error) {
+	sqlEmpty := `UPDATE messages SET content = '', deleted_at = NOW()
+		WHERE id = ANY(ARRAY(
+			SELECT m.id FROM messages m
+			WHERE m.room_id = $1 AND m.created_at < $2 AND m.deleted_at IS NULL
+				AND EXISTS (` + keptReplySql + `)
+			ORDER BY m.created_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		))
+		RETURNING id`
+
+	var messageIDs []string
+	if err = tx.QueryRowContext(ctx, "WITH emptied AS ("+sqlEmpty+") SELECT array_agg(id) FROM emptied", roomID, before, limit).Scan(pq.Array(&messageIDs)); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if len(messageIDs) == 0 {
+		return
+	}
+
+	for _, sqlDependent := range []string{
+		"DELETE FROM message_revisions WHERE message_id = ANY($1::uuid[])",
+		"DELETE FROM message_reactions WHERE message_id = ANY($1::uuid[])",
+	} {
+		if _, err = tx.ExecContext(ctx, sqlDependent, pq.Array(messageIDs)); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+	}
+
+	emptied = int64(len(messageIDs))
+	return
+}
+
+func (repo *ChatRepositoryPostgree) PurgeMessage(ctx context.Context, messageID string) (replyIDs []uuid.UUID, err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	deleted, replyIDs, err := deleteMessageRows(ctx, tx, []string{messageID})
+	if err != nil {
+		return
+	}
+
+	if deleted == 0 {
+		err = sql.ErrNoRows
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+// deleteMessageRows deletes the messages and their thread replies together
+// with the rows pointing at them, it is up to the caller to commit. replyIDs
+// lists the replies that went with their root.
+func deleteMessageRows(ctx context.Context, tx *sql.Tx, messageIDs []string) (deleted int64, replyIDs []uuid.UUID, err error) {
+	sqlReplies := `SELECT array_agg(id ORDER BY created_at, id) FROM messages
+		WHERE parent_message_id = ANY($1::uuid[]) AND NOT id = ANY($1::uuid[])`
+
+	var replies []string
+	if err = tx.QueryRowContext(ctx, sqlReplies, pq.Array(messageIDs)).Scan(pq.Array(&replies)); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	for _, reply := range replies {
+		replyIDs = append(replyIDs, uuid.MustParse(reply))
+	}
+	messageIDs = append(messageIDs, replies...)
+
+	for _, sqlDependent := range []string{
+		"DELETE FROM thread_read_cursors WHERE parent_message_id = ANY($1::uuid[]) OR message_id = ANY($1::uuid[])",
+		"DELETE FROM message_receipts WHERE message_id = ANY($1::uuid[])",
+		"DELETE FROM room_read_cursors WHERE message_id = ANY($1::uuid[])",
+		"DELETE FROM message_revisions WHERE message_id = ANY($1::uuid[])",
+		"DELETE FROM message_reactions WHERE message_id = ANY($1::uuid[])",
+	} {
+		if _, err = tx.ExecContext(ctx, sqlDependent, pq.Array(messageIDs)); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+	}
+
+	// replies go first, their roots are still referenced until then
+	sqlMessage := `DELETE FROM messages WHERE id = ANY($1::uuid[])`
+	for _, sqlDelete := range []string{sqlMessage + " AND parent_message_id IS NOT NULL", sqlMessage} {
+		result, errDelete := tx.ExecContext(ctx, sqlDelete, pq.Array(messageIDs))
+		if errDelete != nil {
+			err = errDelete
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+
+		affected, errAffected := result.RowsAffected()
+		if errAffected != nil {
+			err = errAffected
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		deleted += affected
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) SearchPublicRooms(ctx context.Context, userID string, query string, limit int, offset int) (channels []chat.PublicChannel, total int, err error) {
+	pattern := "%" + escapeLike(query) + "%"
+	sqlMatch := `FROM rooms r
+		WHERE r.visibility = 'public' AND r.archived_at IS NULL
+			AND (r.name ILIKE $1 OR r.topic ILIKE $1)`
+
+	// counted on its own, a page past the end still gets the total
+	if err = repo.db.QueryRowContext(ctx, "SELECT COUNT(*) "+sqlMatch, pattern).Scan(&total); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	sqlRoom := `SELECT r.id, r.name, r.topic, r.description, r.avatar_url,
+			(SELECT COUNT(*) FROM room_members m WHERE m.room_id = r.id),
+			EXISTS(SELECT 1 FROM room_members m WHERE m.room_id = r.id AND m.user_id = $4),
+			r.created_at
+		` + sqlMatch + `
+		ORDER BY r.name, r.id
+		LIMIT $2 OFFSET $3`
+
+	rows, err := repo.db.QueryContext(ctx, sqlRoom, pattern, limit, offset, userID)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var channel chat.PublicChannel
+		if err = rows.Scan(
+			&channel.ID, &channel.Name, &channel.Topic, &channel.Description, &channel.AvatarURL,
+			&channel.MemberCount, &channel.IsMember, &channel.CreatedAt,
+		); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		channels = append(channels, channel)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetMessagesAfterID(ctx context.Context, roomID string, messageID string, limit int) (messages []chat.Message, err error) {
+	sqlMessage := `SELECT ` + messageColumns + `
+		FROM messages m, messages last
+		WHERE last.id = $2 AND last.room_id = $1 AND m.room_id = $1
+			AND (m.created_at, m.id) > (last.created_at, last.id)
+		ORDER BY m.created_at, m.id
+		LIMIT $3`
+
+	rows, err := repo.db.QueryContext(ctx, sqlMessage, roomID, messageID, limit)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message chat.Message
+		if err = scanMessage(rows, &message); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		messages = append(messages, message)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetLatestMessageID(ctx context.Context, roomID string) (messageID uuid.UUID, err error) {
+	sqlMessage := "SELECT id FROM messages WHERE room_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1"
+
+	err = repo.db.QueryRowContext(ctx, sqlMessage, roomID).Scan(&messageID)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetMessagesCreatedAfter(ctx context.Context, createdAfter time.Time, afterID string, limit int) (messages []chat.Message, err error) {
+	// without afterID the messages sharing createdAfter are left out
+	sqlMessage := `SELECT ` + messageColumns + `
+		FROM messages m
+		WHERE m.created_at > $1 OR (m.created_at = $1 AND m.id > $2::uuid)
+		ORDER BY m.created_at, m.id
+		LIMIT $3`
+
+	rows, err := repo.db.QueryContext(ctx, sqlMessage, createdAfter, nullString(afterID), limit)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message chat.Message
+		if err = scanMessage(rows, &message); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		messages = append(messages, message)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetMessageByID(ctx context.Context, messageID string) (message chat.Message, err error) {
+	sqlMessage := "SELECT " + messageColumns + " FROM messages m WHERE m.id = $1"
+
+	err = scanMessage(repo.db.QueryRowContext(ctx, sqlMessage, messageID), &message)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) UpdateMessageContent(ctx context.Context, messageID string, editorID string, content string, editWindow time.Duration) (message chat.Message, err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	// the window is measured by the database clock, the one created_at comes from
+	sqlEditable := `SELECT content FROM messages
+		WHERE id = $1 AND deleted_at IS NULL AND created_at > NOW() - make_interval(secs => $2)
+		FOR UPDATE`
+
+	var previous string
+	if err = tx.QueryRowContext(ctx, sqlEditable, messageID, editWindow.Seconds()).Scan(&previous); err != nil {
+		if err != sql.ErrNoRows {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		}
+		return
+	}
+
+	insertRevisionSql := "INSERT INTO message_revisions (message_id, content, edited_by) VALUES ($1, $2, $3)"
+
+	if _, err = tx.ExecContext(ctx, insertRevisionSql, messageID, previous, editorID); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	updateMessageSql := "UPDATE messages SET content = $2, edited = true, updated_at = NOW() WHERE id = $1"
+
+	if _, err = tx.ExecContext(ctx, updateMessageSql, messageID, content); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = scanMessage(tx.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages m WHERE m.id = $1", messageID), &message); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) DeleteMessageContent(ctx context.Context, messageID string, deletedBy string) (message chat.Message, err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	for _, sqlDependent := range []string{
+		"DELETE FROM message_revisions WHERE message_id = $1",
+		"DELETE FROM message_reactions WHERE message_id = $1",
+	} {
+		if _, err = tx.ExecContext(ctx, sqlDependent, messageID); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+	}
+
+	// created_at and updated_at stay as they were so timelines keep their order
+	deleteContentSql := "UPDATE messages SET content = '', deleted_at = NOW(), deleted_by = $2 WHERE id = $1"
+
+	if _, err = tx.ExecContext(ctx, deleteContentSql, messageID, deletedBy); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = scanMessage(tx.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages m WHERE m.id = $1", messageID), &message); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) InsertReaction(ctx context.Context, messageID string, userID string, emoji string) (reaction chat.MessageReaction, added bool, err error) {
+	insertReactionSql := `WITH inserted AS (
+			INSERT INTO message_reactions (message_id, user_id, emoji) VALUES($1, $2, $3)
+			ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+			RETURNING message_id, user_id, emoji, created_at
+		)
+		SELECT i.message_id, m.room_id, i.user_id, i.emoji, i.created_at
+		FROM inserted i JOIN messages m ON m.id = i.message_id`
+
+	err = repo.db.QueryRowContext(ctx, insertReactionSql, messageID, userID, emoji).Scan(
+		&reaction.MessageID, &reaction.RoomID, &reaction.UserID, &reaction.Emoji, &reaction.CreatedAt,
+	)
+	if err == nil {
+		added = true
+		return
+	}
+
+	if err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	// the user already reacted with this emoji
+	sqlReaction := `SELECT mr.message_id, m.room_id, mr.user_id, mr.emoji, mr.created_at
+		FROM message_reactions mr JOIN messages m ON m.id = mr.message_id
+		WHERE mr.message_id = $1 AND mr.user_id = $2 AND mr.emoji = $3`
+
+	err = repo.db.QueryRowContext(ctx, sqlReaction, messageID, userID, emoji).Scan(
+		&reaction.MessageID, &reaction.RoomID, &reaction.UserID, &reaction.Emoji, &reaction.CreatedAt,
+	)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) DeleteReaction(ctx context.Context, messageID string, userID string, emoji string) (err error) {
+	sqlReaction := "DELETE FROM message_reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3 RETURNING message_id"
+
+	var deletedID string
+	err = repo.db.QueryRowContext(ctx, sqlReaction, messageID, userID, emoji).Scan(&deletedID)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetReactionSummaries(ctx context.Context, messageIDs []string, userEmail string) (summaries map[string][]chat.ReactionSummary, err error) {
+	// emojis come in the order they were first used on each message
+	sqlReaction := `SELECT mr.message_id, mr.emoji, COUNT(*), BOOL_OR(u.email = $2)
+		FROM message_reactions mr JOIN users u ON u.id = mr.user_id
+		WHERE mr.message_id = ANY($1::uuid[])
+		GROUP BY mr.message_id, mr.emoji
+		ORDER BY mr.message_id, MIN(mr.created_at), mr.emoji`
+
+	rows, err := repo.db.QueryContext(ctx, sqlReaction, pq.Array(messageIDs), userEmail)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	summaries = make(map[string][]chat.ReactionSummary)
+	for rows.Next() {
+		var messageID string
+		var summary chat.ReactionSummary
+		if err = rows.Scan(&messageID, &summary.Emoji, &summary.Count, &summary.Reacted); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		summaries[messageID] = append(summaries[messageID], summary)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) InsertThreadReply(ctx context.Context, roomID string, parentMessageID string, userID string, content string, replyToMessageID string) (message chat.Message, err error) {
+	insertReplySql := `INSERT INTO messages (user_id, room_id, content, parent_message_id, reply_to_message_id) VALUES($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	var messageID string
+	if err = repo.db.QueryRowContext(ctx, insertReplySql, userID, roomID, content, parentMessageID, nullString(replyToMessageID)).Scan(&messageID); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	message, err = repo.GetMessageByID(ctx, messageID)
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetThreadReplies(ctx context.Context, parentMessageID string, limit int, offset int) (messages []chat.Message, err error) {
+	sqlMessage := `SELECT ` + messageColumns + `
+		FROM messages m
+		WHERE m.parent_message_id = $1
+		ORDER BY m.created_at, m.id
+		LIMIT $2 OFFSET $3`
+
+	rows, err := repo.db.QueryContext(ctx, sqlMessage, parentMessageID, limit, offset)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message chat.Message
+		if err = scanMessage(rows, &message); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		messages = append(messages, message)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) AdvanceThreadReadCursor(ctx context.Context, parentMessageID string, userID string, messageID string) (cursor chat.ThreadReadCursor, advanced bool, err error) {
+	sqlCursor := `INSERT INTO thread_read_cursors (parent_message_id, user_id, message_id, read_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (parent_message_id, user_id) DO UPDATE SET message_id = EXCLUDED.message_id, read_at = EXCLUDED.read_at
+		WHERE EXISTS (
+			SELECT 1 FROM messages candidate, messages last_read
+			WHERE candidate.id = EXCLUDED.message_id AND last_read.id = thread_read_cursors.message_id
+				AND (candidate.created_at, candidate.id) > (last_read.created_at, last_read.id)
+		)
+		RETURNING parent_message_id, user_id, message_id, read_at`
+
+	err = repo.db.QueryRowContext(ctx, sqlCursor, parentMessageID, userID, messageID).Scan(
+		&cursor.ParentMessageID, &cursor.UserID, &cursor.MessageID, &cursor.ReadAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+			return
+		}
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	advanced = true
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetThreadReadCursor(ctx context.Context, parentMessageID string, userID string) (cursor chat.ThreadReadCursor, err error) {
+	sqlCursor := "SELECT parent_message_id, user_id, message_id, read_at FROM thread_read_cursors WHERE parent_message_id = $1 AND user_id = $2"
+
+	err = repo.db.QueryRowContext(ctx, sqlCursor, parentMessageID, userID).Scan(
+		&cursor.ParentMessageID, &cursor.UserID, &cursor.MessageID, &cursor.ReadAt,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) CountThreadUnread(ctx context.Context, parentMessageID string, userID string) (count int, err error) {
+	sqlUnread := `SELECT COUNT(*) FROM messages m
+		LEFT JOIN thread_read_cursors c ON c.parent_message_id = m.parent_message_id AND c.user_id = $2
+		LEFT JOIN messages lm ON lm.id = c.message_id
+		WHERE m.parent_message_id = $1 AND m.user_id <> $2
+			AND (lm.id IS NULL OR (m.created_at, m.id) > (lm.created_at, lm.id))`
+
+	if err = repo.db.QueryRowContext(ctx, sqlUnread, parentMessageID, userID).Scan(&count); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) MarkThreadMessagesRead(ctx context.Context, parentMessageID string, userID string, lastReadMessageID string) (messages []chat.Message, err error) {
+	sqlReceipt := `INSERT INTO message_receipts (message_id, user_id, delivered_at, read_at)
+		SELECT m.id, $2::uuid, NOW(), NOW()
+		FROM messages m, messages last_read
+		WHERE last_read.id = $3 AND m.parent_message_id = $1 AND m.user_id <> $2::uuid
+			AND (m.created_at, m.id) <= (last_read.created_at, last_read.id)
+		ON CONFLICT (message_id, user_id) DO UPDATE SET read_at = EXCLUDED.read_at
+		WHERE message_receipts.read_at IS NULL
+		RETURNING message_id`
+
+	messages, err = repo.updateReceipts(ctx, sqlReceipt, parentMessageID, userID, lastReadMessageID)
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetMessageRevisions(ctx context.Context, messageID string) (revisions []chat.MessageRevision, err error) {
+	sqlRevision := `SELECT id, message_id, content, edited_by, created_at
+		FROM message_revisions
+		WHERE message_id = $1
+		ORDER BY created_at, id`
+
+	rows, err := repo.db.QueryContext(ctx, sqlRevision, messageID)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var revision chat.MessageRevision
+		if err = rows.Scan(&revision.ID, &revision.MessageID, &revision.Content, &revision.EditedBy, &revision.CreatedAt); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetRoomMatesByEmail(ctx context.Context, userEmail string) (users []chat.User, err error) {
+	sqlUser := `SELECT DISTINCT u.id, u.name, u.email, u.created_at, u.updated_at, u.last_seen_at
+		FROM users me
+		JOIN room_members mine ON mine.user_id = me.id
+		JOIN room_members m ON m.room_id = mine.room_id
+		JOIN users u ON u.id = m.user_id
+		WHERE me.email = $1`
+
+	rows, err := repo.db.QueryContext(ctx, sqlUser, userEmail)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user chat.User
+		if err = rows.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.LastSeenAt); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		users = append(users, user)
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) AdvanceReadCursor(ctx context.Context, roomID string, userID string, messageID string) (cursor chat.ReadCursor, advanced bool, err error) {
+	sqlCursor := `INSERT INTO room_read_cursors (room_id, user_id, message_id, read_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (room_id, user_id) DO UPDATE SET message_id = EXCLUDED.message_id, read_at = EXCLUDED.read_at
+		WHERE EXISTS (
+			SELECT 1 FROM messages candidate, messages last_read
+			WHERE candidate.id = EXCLUDED.message_id AND last_read.id = room_read_cursors.message_id
+				AND (candidate.created_at, candidate.id) > (last_read.created_at, last_read.id)
+		)
+		RETURNING room_id, user_id, message_id, read_at`
+
+	err = repo.db.QueryRowContext(ctx, sqlCursor, roomID, userID, messageID).Scan(
+		&cursor.RoomID, &cursor.UserID, &cursor.MessageID, &cursor.ReadAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+			return
+		}
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	advanced = true
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetReadCursor(ctx context.Context, roomID string, userID string) (cursor chat.ReadCursor, err error) {
+	sqlCursor := "SELECT room_id, user_id, message_id, read_at FROM room_read_cursors WHERE room_id = $1 AND user_id = $2"
+
+	err = repo.db.QueryRowContext(ctx, sqlCursor, roomID, userID).Scan(
+		&cursor.RoomID, &cursor.UserID, &cursor.MessageID, &cursor.ReadAt,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) MarkMessagesDelivered(ctx context.Context, userID string, messageIDs []string) (messages []chat.Message, err error) {
+	// only members other than the sender receive a message, read ones are delivered already
+	sqlReceipt := `INSERT INTO message_receipts (message_id, user_id, delivered_at)
+		SELECT m.id, u.user_id, NOW()
+		FROM messages m
+		JOIN room_members u ON u.room_id = m.room_id AND u.user_id = $1
+		WHERE m.id = ANY($2) AND m.user_id <> u.user_id
+		ON CONFLICT (message_id, user_id) DO NOTHING
+		RETURNING message_id`
+
+	messages, err = repo.updateReceipts(ctx, sqlReceipt, userID, pq.Array(messageIDs))
+	return
+}
+
+// readReceiptBatch bounds the receipts one read writes, older messages keep
+// the status they had.
+const readReceiptBatch = 500
+
+func (repo *ChatRepositoryPostgree) MarkMessagesRead(ctx context.Context, roomID string, userID string, previousMessageID string, lastReadMessageID string) (messages []chat.Message, err error) {
+	// the messages up to the previous cursor got their receipt back then, a
+	// previous cursor that is gone leaves only the batch limit
+	sqlReceipt := `INSERT INTO message_receipts (message_id, user_id, delivered_at, read_at)
+		SELECT unread.id, $2::uuid, NOW(), NOW()
+		FROM (
+			SELECT m.id FROM messages m
+			JOIN messages last_read ON last_read.id = $3
+			LEFT JOIN messages previous ON previous.id = $4::uuid
+			WHERE m.room_id = $1 AND m.user_id <> $2::uuid AND m.parent_message_id IS NULL
+				AND (m.created_at, m.id) <= (last_read.created_at, last_read.id)
+				AND (previous.id IS NULL OR (m.created_at, m.id) > (previous.created_at, previous.id))
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT $5
+		) unread
+		ON CONFLICT (message_id, user_id) DO UPDATE SET read_at = EXCLUDED.read_at
+		WHERE message_receipts.read_at IS NULL
+		RETURNING message_id`
+
+	messages, err = repo.updateReceipts(ctx, sqlReceipt, roomID, userID, lastReadMessageID, nullString(previousMessageID), readReceiptBatch)
+	return
+}
+
+// updateReceipts runs a receipt upsert returning message ids and reads the
+// affected messages back in the same transaction, so their status includes it.
+func (repo *ChatRepositoryPostgree) updateReceipts(ctx context.Context, sqlReceipt string, args ...any) (messages []chat.Message, err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	var messageIDs []string
+	if err = tx.QueryRowContext(ctx, "WITH receipts AS ("+sqlReceipt+") SELECT array_agg(message_id) FROM receipts", args...).Scan(pq.Array(&messageIDs)); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if len(messageIDs) == 0 {
+		return
+	}
+
+	sqlMessage := "SELECT " + messageColumns + " FROM messages m WHERE m.id = ANY($1) ORDER BY m.created_at, m.id"
+
+	rows, err := tx.QueryContext(ctx, sqlMessage, pq.Array(messageIDs))
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message chat.Message
+		if err = scanMessage(rows, &message); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *ChatRepositoryPostgree) GetMessageReceipts(ctx context.Context, messageID string) (receipts []chat.MessageReceipt, err error) {
+	sqlReceipt := `SELECT m.id, u.id, mr.delivered_at, mr.read_at
+		FROM messages m
+		JOIN room_members rm ON rm.room_id = m.room_id AND rm.user_id <> m.user_id
+		JOIN users u ON u.id = rm.user_id
+		LEFT JOIN message_receipts mr ON mr.message_id = m.id AND mr.user_id = u.id
+		WHERE m.id = $1
+		ORDER BY u.name`
+
+	rows, err := repo.db.QueryContext(ctx, sqlReceipt, messageID)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var receipt chat.MessageReceipt
+		if err = rows.Scan(&receipt.MessageID, &receipt.UserID, &receipt.DeliveredAt, &receipt.ReadAt); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+
+		receipt.Status = chat.MessageStatusSent
+		if receipt.ReadAt != nil {
+			receipt.Status = chat.MessageStatusRead
+		} else if receipt.DeliveredAt != nil {
+			receipt.Status = chat.MessageStatusDelivered
+		}
+
+		receipts = append(receipts, receipt)
+	}
+
+	return
+}
+
+// escapeLike makes the wildcards of a search query match literally.
+func escapeLike(query string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query)
+}
+
+// nullString stores an empty optional value as NULL.
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
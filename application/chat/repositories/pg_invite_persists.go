@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+)
+
+type InviteRepositoryPostgree struct {
+	db *sql.DB
+}
+
+func NewInviteRepositoryPostgree(db *sql.DB) chat.IInviteRepository {
+	return &InviteRepositoryPostgree{db}
+}
+
+func (repo *InviteRepositoryPostgree) InsertInvite(ctx context.Context, invite chat.RoomInvite) (created chat.RoomInvite, err error) {
+	insertInviteSql := `INSERT INTO room_invites (room_id, created_by, max_uses, expires_at)
+		VALUES($1, $2, $3, $4)
+		RETURNING id, room_id, created_by, max_uses, uses, expires_at, revoked_at, created_at`
+
+	err = repo.db.QueryRowContext(ctx, insertInviteSql, invite.RoomID, invite.CreatedBy, invite.MaxUses, invite.ExpiresAt).Scan(
+		&created.ID, &created.RoomID, &created.CreatedBy, &created.MaxUses, &created.Uses, &created.ExpiresAt, &created.RevokedAt, &created.CreatedAt,
+	)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	return
+}
+
+func (repo *InviteRepositoryPostgree) GetInviteByID(ctx context.Context, inviteID string) (invite chat.RoomInvite, err error) {
+	sqlInvite := `SELECT id, room_id, created_by, max_uses, uses, expires_at, revoked_at, created_at
+		FROM room_invites WHERE id = $1`
+
+	err = repo.db.QueryRowContext(ctx, sqlInvite, inviteID).Scan(
+		&invite.ID, &invite.RoomID, &invite.CreatedBy, &invite.MaxUses, &invite.Uses, &invite.ExpiresAt, &invite.RevokedAt, &invite.CreatedAt,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *InviteRepositoryPostgree) GetActiveInvitesByRoomID(ctx context.Context, roomID string) (invites []chat.RoomInvite, err error) {
+	sqlInvite := `SELECT id, room_id, created_by, max_uses, uses, expires_at, revoked_at, created_at
+		FROM room_invites
+		WHERE room_id = $1 AND revoked_at IS NULL AND expires_at > NOW() AND uses < max_uses
+		ORDER BY created_at DESC`
+
+	rows, err := repo.db.QueryContext(ctx, sqlInvite, roomID)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var invite chat.RoomInvite
+		if err = rows.Scan(&invite.ID, &invite.RoomID, &invite.CreatedBy, &invite.MaxUses, &invite.Uses, &invite.ExpiresAt, &invite.RevokedAt, &invite.CreatedAt); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		invites = append(invites, invite)
+	}
+
+	return
+}
+
+func (repo *InviteRepositoryPostgree) RevokeInvite(ctx context.Context, roomID string, inviteID string) (err error) {
+	sqlInvite := "UPDATE room_invites SET revoked_at = NOW() WHERE id = $1 AND room_id = $2 AND revoked_at IS NULL RETURNING id"
+
+	var revokedID string
+	err = repo.db.QueryRowContext(ctx, sqlInvite, inviteID, roomID).Scan(&revokedID)
+	if err != nil && err != sql.ErrNoRows {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+
+	return
+}
+
+func (repo *InviteRepositoryPostgree) RedeemInvite(ctx context.Context, inviteID string, userID string) (member chat.RoomMember, joined bool, err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	// the checks and the increment happen in one statement so concurrent
+	// redemptions cannot go over max_uses
+	sqlUse := `UPDATE room_invites SET uses = uses + 1
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW() AND uses < max_uses
+		RETURNING room_id`
+
+	var roomID string
+	if err = tx.QueryRowContext(ctx, sqlUse, inviteID).Scan(&roomID); err != nil {
+		if err != sql.ErrNoRows {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		}
+		return
+	}
+
+	// a concurrent accept of the same user ends here, the rollback gives the use back
+	insertMemberSql := `INSERT INTO room_members (room_id, user_id, role) VALUES($1, $2, 'member')
+		ON CONFLICT (room_id, user_id) DO NOTHING
+		RETURNING room_id, user_id, role, joined_at`
+
+	if err = tx.QueryRowContext(ctx, insertMemberSql, roomID, userID).Scan(&member.RoomID, &member.UserID, &member.Role, &member.JoinedAt); err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+			return
+		}
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = tx.QueryRowContext(ctx, "SELECT name, email FROM users WHERE id = $1", userID).Scan(&member.Name, &member.Email); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	// a member who left can come back with the same invite
+	insertRedemptionSql := `INSERT INTO room_invite_redemptions (invite_id, user_id) VALUES($1, $2)
+		ON CONFLICT (invite_id, user_id) DO UPDATE SET redeemed_at = NOW()`
+
+	if _, err = tx.ExecContext(ctx, insertRedemptionSql, inviteID, userID); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	joined = true
+	return
+}
+
+func (repo *InviteRepositoryPostgree) GetInviteRedemptions(ctx context.Context, inviteID string) (redemptions []chat.InviteRedemption, err error) {
+	sqlRedemption := `SELECT r.invite_id, r.user_id, u.name, u.email, r.redeemed_at
+		FROM room_invite_redemptions r JOIN users u ON u.id = r.user_id
+		WHERE r.invite_id = $1
+		ORDER BY r.redeemed_at`
+
+	rows, err := repo.db.QueryContext(ctx, sqlRedemption, inviteID)
+	if err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var redemption chat.InviteRedemption
+		if err = rows.Scan(&redemption.InviteID, &redemption.UserID, &redemption.Name, &redemption.Email, &redemption.RedeemedAt); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			return
+		}
+		redemptions = append(redemptions, redemption)
+	}
+
+	return
+}
@@ -2,9 +2,11 @@ package repositories
 
 import (
 	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
 	"context"
 	"database/sql"
 	"log"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -18,7 +20,7 @@ func NewUserRepositoryPostgree(db *sql.DB) chat.IUserRepository {
 }
 
 func (repo *UserRepositoryPostgree) GetUserByID(ctx context.Context, id string) (user chat.User, err error) {
-	sql := "SELECT * FROM users WHERE id = $1"
+	sql := "SELECT id, name, email, password, created_at, updated_at, last_seen_at FROM users WHERE id = $1"
 	row := repo.db.QueryRowContext(ctx, sql, id)
 	if row.Err() != nil {
 		err = row.Err()
@@ -26,7 +28,7 @@ func (repo *UserRepositoryPostgree) GetUserByID(ctx context.Context, id string)
 		return
 	}
 
-	if err = row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt); err != nil {
+	if err = row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt, &user.LastSeenAt); err != nil {
 		return
 	}
 
@@ -34,7 +36,7 @@ func (repo *UserRepositoryPostgree) GetUserByID(ctx context.Context, id string)
 }
 
 func (repo *UserRepositoryPostgree) GetUserByEmail(ctx context.Context, email string) (user chat.User, err error) {
-	sql := "SELECT * FROM users WHERE email = $1"
+	sql := "SELECT id, name, email, password, created_at, updated_at, last_seen_at FROM users WHERE email = $1"
 	row := repo.db.QueryRowContext(ctx, sql, email)
 	if row.Err() != nil {
 		err = row.Err()
@@ -42,7 +44,7 @@ func (repo *UserRepositoryPostgree) GetUserByEmail(ctx context.Context, email st
 		return
 	}
 
-	if err = row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt); err != nil {
+	if err = row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt, &user.LastSeenAt); err != nil {
 		return
 	}
 
@@ -66,4 +68,15 @@ func (repo *UserRepositoryPostgree) CreateUser(ctx context.Context, user chat.Us
 	}
 
 	return
-}
\ No newline at end of file
+}
+
+func (repo *UserRepositoryPostgree) UpdateLastSeen(ctx context.Context, id string, lastSeenAt time.Time) (err error) {
+	sql := "UPDATE users SET last_seen_at = $2 WHERE id = $1"
+
+	if _, err = repo.db.ExecContext(ctx, sql, id, lastSeenAt); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		return
+	}
+
+	return
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// directRoomRepository has a direct room between two users, left is true once
+// the current user left it.
+type directRoomRepository struct {
+	chat.IChatRepository
+	roomID string
+	left   bool
+}
+
+func (repo *directRoomRepository) GetDirectRoomID(ctx context.Context, currentUserEmail string, targetEmail string) (string, error) {
+	return repo.roomID, nil
+}
+
+func (repo *directRoomRepository) GetRoomMember(ctx context.Context, roomID string, userEmail string) (chat.RoomMember, error) {
+	if repo.left || roomID != repo.roomID {
+		return chat.RoomMember{}, sql.ErrNoRows
+	}
+	return chat.RoomMember{Role: chat.RoomRoleMember}, nil
+}
+
+func (repo *directRoomRepository) GetMessage(ctx context.Context, params chat.MessageHistoryParams) ([]chat.Message, error) {
+	return nil, nil
+}
+
+func TestDirectRoomHistory(t *testing.T) {
+	for name, test := range map[string]struct {
+		left bool
+		err  error
+	}{
+		"member":      {false, nil},
+		"left the dm": {true, usecases.ErrNotRoomMember},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := &directRoomRepository{roomID: uuid.NewString(), left: test.left}
+			uc := usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+
+			_, err := uc.GetMessages(context.Background(), chat.MessageHistoryParams{
+				TargetEmail:      "friend@mail.com",
+				CurrentUserEmail: "me@mail.com",
+			})
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+func TestDirectRoomKey(t *testing.T) {
+	alice := uuid.MustParse("8f14e45f-ceea-467f-a0e6-0d2a5f2f3c1b")
+	bob := uuid.MustParse("1c9ac015-9a2f-4c1e-8e2a-6b1f0f6d7e21")
+
+	t.Run("same key whatever the order", func(t *testing.T) {
+		assert.Equal(t, chat.DirectRoomKey(alice, bob), chat.DirectRoomKey(bob, alice))
+	})
+
+	t.Run("ids are sorted like the migration sorts them", func(t *testing.T) {
+		assert.Equal(t, "1c9ac015-9a2f-4c1e-8e2a-6b1f0f6d7e21:8f14e45f-ceea-467f-a0e6-0d2a5f2f3c1b", chat.DirectRoomKey(alice, bob))
+	})
+
+	t.Run("differs for another pair", func(t *testing.T) {
+		assert.NotEqual(t, chat.DirectRoomKey(alice, bob), chat.DirectRoomKey(alice, uuid.New()))
+	})
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/realtime"
+	"github.com/fikrihkll/chat-app/infrastructure"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPubSubEventBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// two replicas sharing the in-memory stand-in for redis
+	sharedState := infrastructure.NewMemorySharedState()
+
+	hubA := realtime.NewHub(realtime.DefaultSubscriptionBuffer)
+	busA := realtime.NewPubSubEventBus(sharedState, hubA)
+	go busA.Listen(ctx)
+
+	hubB := realtime.NewHub(realtime.DefaultSubscriptionBuffer)
+	busB := realtime.NewPubSubEventBus(sharedState, hubB)
+	go busB.Listen(ctx)
+
+	// let both replicas subscribe
+	time.Sleep(50 * time.Millisecond)
+
+	recipient := uuid.New()
+	stranger := uuid.New()
+
+	t.Run("event reaches recipient on another replica", func(t *testing.T) {
+		sub := hubB.Subscribe(recipient)
+		defer sub.Close()
+
+		message := chat.Message{ID: uuid.New(), RoomID: uuid.New(), Content: "hi", CreatedAt: time.Now()}
+		assert.NoError(t, busA.Publish(ctx, chat.NewMessageCreatedEvent(message, []uuid.UUID{recipient})))
+
+		select {
+		case event := <-sub.Events():
+			assert.Equal(t, message.ID.String(), event.ID)
+			assert.Equal(t, []uuid.UUID{recipient}, event.Recipients)
+		case <-time.After(time.Second):
+			t.Fatal("event was not delivered")
+		}
+	})
+
+	t.Run("event skips users that are not recipients", func(t *testing.T) {
+		sub := hubA.Subscribe(stranger)
+		defer sub.Close()
+
+		message := chat.Message{ID: uuid.New(), RoomID: uuid.New(), Content: "hi", CreatedAt: time.Now()}
+		assert.NoError(t, busB.Publish(ctx, chat.NewMessageCreatedEvent(message, []uuid.UUID{recipient})))
+
+		select {
+		case <-sub.Events():
+			t.Fatal("event delivered to a non recipient")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+func TestMemorySharedState(t *testing.T) {
+	ctx := context.Background()
+	state := infrastructure.NewMemorySharedState()
+
+	t.Run("incr expires with its ttl", func(t *testing.T) {
+		value, err := state.Incr(ctx, "counter", 50*time.Millisecond)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), value)
+
+		value, _ = state.Incr(ctx, "counter", 50*time.Millisecond)
+		assert.Equal(t, int64(2), value)
+
+		time.Sleep(60 * time.Millisecond)
+		value, _ = state.Incr(ctx, "counter", 50*time.Millisecond)
+		assert.Equal(t, int64(1), value)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := state.Get(ctx, "missing")
+		assert.ErrorIs(t, err, infrastructure.ErrKeyNotFound)
+	})
+
+	t.Run("set members expire on their own", func(t *testing.T) {
+		count, err := state.AddToSet(ctx, "connections", "a", time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		count, _ = state.AddToSet(ctx, "connections", "b", time.Now().Add(50*time.Millisecond))
+		assert.Equal(t, int64(2), count)
+
+		time.Sleep(60 * time.Millisecond)
+		count, _ = state.CountSet(ctx, "connections")
+		assert.Equal(t, int64(1), count)
+
+		count, _ = state.RemoveFromSet(ctx, "connections", "a")
+		assert.Equal(t, int64(0), count)
+	})
+}
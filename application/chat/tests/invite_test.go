@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// inviteRepository holds a single invite. revokeOnRedeem revokes it right
+// before the redemption, alreadyMember makes the redemption find the user in.
+type inviteRepository struct {
+	chat.IChatRepository
+	chat.IInviteRepository
+	invite         chat.RoomInvite
+	revokeOnRedeem bool
+	alreadyMember  bool
+}
+
+func (repo *inviteRepository) GetInviteByID(ctx context.Context, inviteID string) (chat.RoomInvite, error) {
+	if inviteID != repo.invite.ID.String() {
+		return chat.RoomInvite{}, sql.ErrNoRows
+	}
+	return repo.invite, nil
+}
+
+func (repo *inviteRepository) GetRoomByID(ctx context.Context, roomID string) (chat.Room, error) {
+	return chat.Room{ID: repo.invite.RoomID}, nil
+}
+
+func (repo *inviteRepository) IsBannedFromRoom(ctx context.Context, roomID string, userID string) (bool, error) {
+	return false, nil
+}
+
+func (repo *inviteRepository) GetRoomMembers(ctx context.Context, roomID string) ([]chat.User, error) {
+	return nil, nil
+}
+
+func (repo *inviteRepository) RedeemInvite(ctx context.Context, inviteID string, userID string) (chat.RoomMember, bool, error) {
+	if repo.revokeOnRedeem {
+		revokedAt := time.Now()
+		repo.invite.RevokedAt = &revokedAt
+		return chat.RoomMember{}, false, sql.ErrNoRows
+	}
+	if repo.alreadyMember {
+		return chat.RoomMember{}, false, nil
+	}
+	return chat.RoomMember{RoomID: repo.invite.RoomID, UserID: uuid.MustParse(userID), Role: chat.RoomRoleMember}, true, nil
+}
+
+func signInviteToken(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	assert.NoError(t, err)
+	return token
+}
+
+func TestAcceptInvite(t *testing.T) {
+	t.Setenv("SECRET", "invite-test-secret")
+	secret := []byte("invite-test-secret")
+
+	invite := chat.RoomInvite{ID: uuid.New(), RoomID: uuid.New(), MaxUses: 5, ExpiresAt: time.Now().Add(time.Hour)}
+	inviteClaims := func(audience string, expiresAt time.Time) jwt.MapClaims {
+		return jwt.MapClaims{"invite_id": invite.ID.String(), "aud": audience, "exp": expiresAt.Unix()}
+	}
+	validToken := signInviteToken(t, jwt.SigningMethodHS256, secret, inviteClaims("room_invite", invite.ExpiresAt))
+
+	accept := func(repo *inviteRepository, token string) error {
+		uc := usecases.NewInviteApplication(repo, repo, &recordingPublisher{})
+		_, err := uc.AcceptInvite(context.Background(), chat.AcceptInviteParam{
+			CurrentUserID:    uuid.NewString(),
+			CurrentUserEmail: "joiner@mail.com",
+			Token:            token,
+		})
+		return err
+	}
+
+	for name, test := range map[string]struct {
+		token string
+		err   error
+	}{
+		"valid token":      {validToken, nil},
+		"login token":      {signInviteToken(t, jwt.SigningMethodHS256, secret, inviteClaims("", invite.ExpiresAt)), usecases.ErrInvalidInvite},
+		"other audience":   {signInviteToken(t, jwt.SigningMethodHS256, secret, inviteClaims("room", invite.ExpiresAt)), usecases.ErrInvalidInvite},
+		"expired token":    {signInviteToken(t, jwt.SigningMethodHS256, secret, inviteClaims("room_invite", time.Now().Add(-time.Minute))), usecases.ErrInviteExpired},
+		"no expiry":        {signInviteToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"invite_id": invite.ID.String(), "aud": "room_invite"}), usecases.ErrInvalidInvite},
+		"other algorithm":  {signInviteToken(t, jwt.SigningMethodHS512, secret, inviteClaims("room_invite", invite.ExpiresAt)), usecases.ErrInvalidInvite},
+		"unsigned":         {signInviteToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, inviteClaims("room_invite", invite.ExpiresAt)), usecases.ErrInvalidInvite},
+		"other secret":     {signInviteToken(t, jwt.SigningMethodHS256, []byte("other"), inviteClaims("room_invite", invite.ExpiresAt)), usecases.ErrInvalidInvite},
+		"not an invite id": {signInviteToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"invite_id": "1", "aud": "room_invite", "exp": invite.ExpiresAt.Unix()}), usecases.ErrInvalidInvite},
+		"not even a token": {"invite", usecases.ErrInvalidInvite},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, accept(&inviteRepository{invite: invite}, test.token), test.err)
+		})
+	}
+
+	t.Run("revoked while redeeming", func(t *testing.T) {
+		assert.ErrorIs(t, accept(&inviteRepository{invite: invite, revokeOnRedeem: true}, validToken), usecases.ErrInviteRevoked)
+	})
+
+	t.Run("already a member", func(t *testing.T) {
+		assert.ErrorIs(t, accept(&inviteRepository{invite: invite, alreadyMember: true}, validToken), usecases.ErrAlreadyRoomMember)
+	})
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// messageRepository holds a single message, the current user has role in
+// its room. expired makes edits miss the window.
+type messageRepository struct {
+	chat.IChatRepository
+	message chat.Message
+	role    string
+	expired bool
+	purged  bool
+}
+
+func (repo *messageRepository) GetMessageByID(ctx context.Context, messageID string) (chat.Message, error) {
+	return repo.message, nil
+}
+
+func (repo *messageRepository) GetRoomMember(ctx context.Context, roomID string, userEmail string) (chat.RoomMember, error) {
+	return chat.RoomMember{RoomID: repo.message.RoomID, Role: repo.role}, nil
+}
+
+func (repo *messageRepository) GetRoomByID(ctx context.Context, roomID string) (chat.Room, error) {
+	return chat.Room{ID: repo.message.RoomID}, nil
+}
+
+func (repo *messageRepository) GetRoomMembers(ctx context.Context, roomID string) ([]chat.User, error) {
+	return nil, nil
+}
+
+func (repo *messageRepository) UpdateMessageContent(ctx context.Context, messageID string, editorID string, content string, editWindow time.Duration) (chat.Message, error) {
+	if repo.expired {
+		return chat.Message{}, sql.ErrNoRows
+	}
+	repo.message.Content = content
+	repo.message.Edited = true
+	return repo.message, nil
+}
+
+func (repo *messageRepository) DeleteMessageContent(ctx context.Context, messageID string, deletedBy string) (chat.Message, error) {
+	deletedAt := time.Now()
+	repo.message.Content = ""
+	repo.message.DeletedAt = &deletedAt
+	return repo.message, nil
+}
+
+func (repo *messageRepository) PurgeMessage(ctx context.Context, messageID string) ([]uuid.UUID, error) {
+	repo.purged = true
+	return nil, nil
+}
+
+func TestEditMessage(t *testing.T) {
+	sender := uuid.New()
+	deletedAt := time.Now()
+	message := chat.Message{ID: uuid.New(), RoomID: uuid.New(), UserID: sender, Kind: chat.MessageKindUser, Content: "hello"}
+
+	system := message
+	system.Kind = chat.MessageKindSystem
+	tombstone := message
+	tombstone.DeletedAt = &deletedAt
+
+	for name, test := range map[string]struct {
+		message chat.Message
+		userID  uuid.UUID
+		expired bool
+		err     error
+	}{
+		"sender within the window": {message, sender, false, nil},
+		"sender after the window":  {message, sender, true, usecases.ErrEditWindowExpired},
+		"someone else":             {message, uuid.New(), false, usecases.ErrNotMessageSender},
+		"system message":           {system, sender, false, usecases.ErrMessageNotEditable},
+		"tombstone":                {tombstone, sender, false, usecases.ErrMessageNotEditable},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := &messageRepository{message: test.message, role: chat.RoomRoleMember, expired: test.expired}
+			uc := usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+
+			edited, err := uc.EditMessage(context.Background(), chat.EditMessageParam{
+				CurrentUserID:    test.userID.String(),
+				CurrentUserEmail: "member@mail.com",
+				MessageID:        test.message.ID.String(),
+				Content:          "hello again",
+			})
+			assert.ErrorIs(t, err, test.err)
+			if test.err == nil {
+				assert.True(t, edited.Edited)
+			}
+		})
+	}
+}
+
+func TestDeleteAndPurgeMessage(t *testing.T) {
+	sender := uuid.New()
+	message := chat.Message{ID: uuid.New(), RoomID: uuid.New(), UserID: sender, Kind: chat.MessageKindUser, Content: "hello"}
+
+	for name, test := range map[string]struct {
+		userID    uuid.UUID
+		role      string
+		deleteErr error
+		purgeErr  error
+	}{
+		"sender":       {sender, chat.RoomRoleMember, nil, usecases.ErrRoomPermissionDenied},
+		"other member": {uuid.New(), chat.RoomRoleMember, usecases.ErrRoomPermissionDenied, usecases.ErrRoomPermissionDenied},
+		"admin":        {uuid.New(), chat.RoomRoleAdmin, nil, nil},
+		"owner":        {uuid.New(), chat.RoomRoleOwner, nil, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			params := chat.DeleteMessageParam{
+				CurrentUserID:    test.userID.String(),
+				CurrentUserEmail: "member@mail.com",
+				MessageID:        message.ID.String(),
+			}
+
+			repo := &messageRepository{message: message, role: test.role}
+			uc := usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+			deleted, err := uc.DeleteMessage(context.Background(), params)
+			assert.ErrorIs(t, err, test.deleteErr)
+			if test.deleteErr == nil {
+				assert.NotNil(t, deleted.DeletedAt)
+			}
+
+			repo = &messageRepository{message: message, role: test.role}
+			uc = usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+			_, err = uc.PurgeMessage(context.Background(), params)
+			assert.ErrorIs(t, err, test.purgeErr)
+			assert.Equal(t, test.purgeErr == nil, repo.purged)
+		})
+	}
+}
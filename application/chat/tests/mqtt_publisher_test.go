@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/realtime"
+	"github.com/fikrihkll/chat-app/config"
+	"github.com/fikrihkll/chat-app/infrastructure"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMqttEventPublisher(t *testing.T) {
+	t.Setenv("SECRET", "mqtt-test-secret")
+
+	memberRoomID := uuid.New()
+	isRoomMember := func(ctx context.Context, roomID string, userEmail string) (bool, error) {
+		return roomID == memberRoomID.String() && userEmail == "member@mail.com", nil
+	}
+
+	broker, err := infrastructure.NewMqttBroker(
+		config.ApplicationConfig{MqttAddress: "127.0.0.1:0"},
+		realtime.NewMqttAuthHook(isRoomMember),
+	)
+	assert.NoError(t, err)
+	defer broker.Close()
+
+	listener, _ := broker.Listeners.Get("tcp")
+	brokerURL := fmt.Sprintf("tcp://%s", listener.Address())
+	publisher := realtime.NewMqttBrokerEventPublisher(broker)
+
+	t.Run("member receives saved message", func(t *testing.T) {
+		client, err := connectMqtt(brokerURL, signToken(uuid.NewString(), "member@mail.com"))
+		assert.NoError(t, err)
+		defer client.Disconnect(0)
+
+		received := make(chan chat.Event, 1)
+		token := client.Subscribe(realtime.MqttRoomTopic(memberRoomID), 1, func(c paho.Client, m paho.Message) {
+			var event chat.Event
+			json.Unmarshal(m.Payload(), &event)
+			received <- event
+		})
+		assert.True(t, token.WaitTimeout(5*time.Second))
+		assert.Less(t, token.(*paho.SubscribeToken).Result()[realtime.MqttRoomTopic(memberRoomID)], byte(0x80))
+
+		message := chat.Message{ID: uuid.New(), RoomID: memberRoomID, Content: "hello", CreatedAt: time.Now()}
+		assert.NoError(t, publisher.Publish(context.Background(), chat.NewMessageCreatedEvent(message, nil)))
+
+		select {
+		case event := <-received:
+			assert.Equal(t, message.ID.String(), event.ID)
+			assert.Equal(t, chat.EventMessageCreated, event.Type)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message was not delivered")
+		}
+	})
+
+	t.Run("non member cannot subscribe", func(t *testing.T) {
+		client, err := connectMqtt(brokerURL, signToken(uuid.NewString(), "stranger@mail.com"))
+		assert.NoError(t, err)
+		defer client.Disconnect(0)
+
+		token := client.Subscribe(realtime.MqttRoomTopic(memberRoomID), 1, nil)
+		assert.True(t, token.WaitTimeout(5*time.Second))
+		assert.GreaterOrEqual(t, token.(*paho.SubscribeToken).Result()[realtime.MqttRoomTopic(memberRoomID)], byte(0x80))
+	})
+
+	t.Run("invalid token is rejected", func(t *testing.T) {
+		_, err := connectMqtt(brokerURL, "not-a-token")
+		assert.Error(t, err)
+	})
+}
+
+func connectMqtt(brokerURL string, password string) (paho.Client, error) {
+	client := paho.NewClient(paho.NewClientOptions().
+		AddBroker(brokerURL).
+		SetClientID(uuid.NewString()).
+		SetUsername("flutter").
+		SetPassword(password))
+
+	token := client.Connect()
+	token.WaitTimeout(5 * time.Second)
+	return client, token.Error()
+}
+
+func signToken(userID string, email string) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"email":   email,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(os.Getenv("SECRET")))
+
+	return token
+}
@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationSettings(t *testing.T) {
+	now := time.Now()
+
+	t.Run("levels", func(t *testing.T) {
+		all := chat.NotificationSettings{Level: chat.NotificationLevelAll}
+		assert.True(t, all.Notifies(false, now))
+
+		mentions := chat.NotificationSettings{Level: chat.NotificationLevelMentions}
+		assert.False(t, mentions.Notifies(false, now))
+		assert.True(t, mentions.Notifies(true, now))
+
+		none := chat.NotificationSettings{Level: chat.NotificationLevelNone}
+		assert.False(t, none.Notifies(true, now))
+	})
+
+	t.Run("muted until further notice", func(t *testing.T) {
+		settings := chat.NotificationSettings{Level: chat.NotificationLevelAll, Muted: true}
+		assert.False(t, settings.Notifies(true, now))
+	})
+
+	t.Run("mute runs out", func(t *testing.T) {
+		until := now.Add(time.Hour)
+		settings := chat.NotificationSettings{Level: chat.NotificationLevelAll, Muted: true, MutedUntil: &until}
+		assert.False(t, settings.Notifies(true, now))
+		assert.True(t, settings.Notifies(true, now.Add(2*time.Hour)))
+	})
+}
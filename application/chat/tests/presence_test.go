@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/repositories"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/fikrihkll/chat-app/infrastructure"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// presenceRepository knows a few users and who shares a room with whom.
+type presenceRepository struct {
+	chat.IUserRepository
+	chat.IChatRepository
+	users     []chat.User
+	roomMates map[string][]chat.User
+}
+
+func (repo *presenceRepository) GetUserByID(ctx context.Context, id string) (chat.User, error) {
+	for _, user := range repo.users {
+		if user.ID.String() == id {
+			return user, nil
+		}
+	}
+	return chat.User{}, sql.ErrNoRows
+}
+
+func (repo *presenceRepository) UpdateLastSeen(ctx context.Context, id string, lastSeenAt time.Time) error {
+	return nil
+}
+
+func (repo *presenceRepository) GetRoomMatesByEmail(ctx context.Context, userEmail string) ([]chat.User, error) {
+	return repo.roomMates[userEmail], nil
+}
+
+func TestPresence(t *testing.T) {
+	me := chat.User{ID: uuid.New(), Email: "me@mail.com"}
+	roomMate := chat.User{ID: uuid.New(), Email: "mate@mail.com"}
+	stranger := chat.User{ID: uuid.New(), Email: "stranger@mail.com"}
+
+	repo := &presenceRepository{
+		users: []chat.User{me, roomMate, stranger},
+		roomMates: map[string][]chat.User{
+			me.Email:       {me, roomMate},
+			roomMate.Email: {me, roomMate},
+		},
+	}
+	store := repositories.NewPresenceStoreKeyValue(infrastructure.NewMemorySharedState())
+
+	t.Run("only room mates can see a presence", func(t *testing.T) {
+		uc := usecases.NewPresenceApplication(store, repo, repo, &recordingPublisher{})
+
+		_, err := uc.GetPresence(context.Background(), me.Email, me.ID.String())
+		assert.NoError(t, err)
+		_, err = uc.GetPresence(context.Background(), me.Email, roomMate.ID.String())
+		assert.NoError(t, err)
+		_, err = uc.GetPresence(context.Background(), me.Email, stranger.ID.String())
+		assert.ErrorIs(t, err, common.UserNotFoundError)
+	})
+
+	t.Run("expired connections go offline once", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		uc := usecases.NewPresenceApplication(store, repo, repo, publisher)
+
+		_, err := store.AddConnection(context.Background(), roomMate.ID.String(), uuid.NewString(), time.Millisecond)
+		assert.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+
+		assert.NoError(t, uc.ExpireConnections(context.Background()))
+		assert.NoError(t, uc.ExpireConnections(context.Background()))
+
+		assert.Len(t, publisher.events, 1)
+		assert.Equal(t, chat.EventPresenceChanged, publisher.events[0].Type)
+		assert.Equal(t, chat.PresenceOffline, publisher.events[0].Data.(chat.Presence).Status)
+	})
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotedReply(t *testing.T) {
+	deletedAt := time.Now()
+	elsewhere := chat.Message{ID: uuid.New(), RoomID: uuid.New()}
+	deleted := chat.Message{ID: uuid.New(), RoomID: uuid.New(), DeletedAt: &deletedAt}
+
+	repo := &threadRepository{messages: map[string]chat.Message{
+		elsewhere.ID.String(): elsewhere,
+		deleted.ID.String():   deleted,
+	}}
+	uc := usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+
+	for name, test := range map[string]struct {
+		roomID  uuid.UUID
+		replyTo string
+		err     error
+	}{
+		"message of another room": {uuid.New(), elsewhere.ID.String(), usecases.ErrQuoteNotInRoom},
+		"unknown message":         {elsewhere.RoomID, uuid.NewString(), usecases.ErrQuoteNotInRoom},
+		"deleted message":         {deleted.RoomID, deleted.ID.String(), usecases.ErrMessageDeleted},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := uc.SaveMessageByRoomID(context.Background(), chat.NewMessageByRoomIDParam{
+				CurrentUserID:    uuid.NewString(),
+				CurrentUserEmail: "member@mail.com",
+				RoomID:           test.roomID.String(),
+				Message:          "quoting",
+				ReplyToMessageID: test.replyTo,
+			})
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/transport"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// reactionRepository holds a single message and its reactions in memory.
+type reactionRepository struct {
+	chat.IChatRepository
+	message   chat.Message
+	reactions map[string]bool
+}
+
+func (repo *reactionRepository) GetMessageByID(ctx context.Context, messageID string) (chat.Message, error) {
+	return repo.message, nil
+}
+
+func (repo *reactionRepository) GetRoomMember(ctx context.Context, roomID string, userEmail string) (chat.RoomMember, error) {
+	return chat.RoomMember{RoomID: repo.message.RoomID, Role: chat.RoomRoleMember}, nil
+}
+
+func (repo *reactionRepository) GetRoomByID(ctx context.Context, roomID string) (chat.Room, error) {
+	return chat.Room{ID: repo.message.RoomID}, nil
+}
+
+func (repo *reactionRepository) GetRoomMembers(ctx context.Context, roomID string) ([]chat.User, error) {
+	return []chat.User{{ID: repo.message.UserID}}, nil
+}
+
+func (repo *reactionRepository) InsertReaction(ctx context.Context, messageID string, userID string, emoji string) (chat.MessageReaction, bool, error) {
+	added := !repo.reactions[userID+emoji]
+	repo.reactions[userID+emoji] = true
+	return chat.MessageReaction{MessageID: repo.message.ID, RoomID: repo.message.RoomID, Emoji: emoji}, added, nil
+}
+
+type recordingPublisher struct {
+	events []chat.Event
+}
+
+func (publisher *recordingPublisher) Publish(ctx context.Context, event chat.Event) error {
+	publisher.events = append(publisher.events, event)
+	return nil
+}
+
+func TestAddReaction(t *testing.T) {
+	message := chat.Message{ID: uuid.New(), RoomID: uuid.New(), UserID: uuid.New(), Kind: chat.MessageKindUser}
+	params := chat.ReactionParam{
+		CurrentUserID:    uuid.NewString(),
+		CurrentUserEmail: "member@mail.com",
+		MessageID:        message.ID.String(),
+		Emoji:            "👍",
+	}
+
+	t.Run("broadcasts a new reaction once", func(t *testing.T) {
+		repo := &reactionRepository{message: message, reactions: map[string]bool{}}
+		publisher := &recordingPublisher{}
+		uc := usecases.NewChatApplication(repo, nil, publisher, nil, time.Minute)
+
+		_, err := uc.AddReaction(context.Background(), params)
+		assert.NoError(t, err)
+		_, err = uc.AddReaction(context.Background(), params)
+		assert.NoError(t, err)
+
+		assert.Len(t, publisher.events, 1)
+		assert.Equal(t, chat.EventReactionAdded, publisher.events[0].Type)
+	})
+
+	t.Run("refuses tombstones", func(t *testing.T) {
+		deleted := message
+		deletedAt := time.Now()
+		deleted.DeletedAt = &deletedAt
+
+		repo := &reactionRepository{message: deleted, reactions: map[string]bool{}}
+		uc := usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+
+		_, err := uc.AddReaction(context.Background(), params)
+		assert.ErrorIs(t, err, usecases.ErrMessageDeleted)
+	})
+}
+
+func TestReactionEmoji(t *testing.T) {
+	for _, emoji := range []string{"👍", "❤️", "👍🏽", "👨‍👩‍👧", "🏳️‍🌈", "🇮🇩", "1️⃣", "🏴󠁧󠁢󠁳󠁣󠁴󠁿", ":thumbsup:", ":white_check_mark:", ":+1:"} {
+		assert.NoError(t, transport.Reaction{Emoji: emoji}.Validate(), emoji)
+	}
+
+	for _, emoji := range []string{"", "lol", "<script>", "DROP", "👍 👍", ":Thumbs Up:", "::", "a👍", "👍a", "\u200d"} {
+		assert.Error(t, transport.Reaction{Emoji: emoji}.Validate(), emoji)
+	}
+}
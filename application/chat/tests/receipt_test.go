@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// receiptRepository returns the same messages for every receipt.
+type receiptRepository struct {
+	chat.IChatRepository
+	messages []chat.Message
+}
+
+func (repo *receiptRepository) MarkMessagesDelivered(ctx context.Context, userID string, messageIDs []string) ([]chat.Message, error) {
+	return repo.messages, nil
+}
+
+func TestAcknowledgeDelivery(t *testing.T) {
+	roomID := uuid.New()
+	sender, other := uuid.New(), uuid.New()
+
+	repo := &receiptRepository{messages: []chat.Message{
+		{ID: uuid.New(), RoomID: roomID, UserID: sender, Status: chat.MessageStatusDelivered},
+		{ID: uuid.New(), RoomID: roomID, UserID: other, Status: chat.MessageStatusDelivered},
+		{ID: uuid.New(), RoomID: roomID, UserID: sender, Status: chat.MessageStatusDelivered},
+		{ID: uuid.New(), RoomID: roomID, UserID: other, Status: chat.MessageStatusSent},
+	}}
+	publisher := &recordingPublisher{}
+	uc := usecases.NewChatApplication(repo, nil, publisher, nil, time.Minute)
+
+	err := uc.AcknowledgeDelivery(context.Background(), chat.DeliveryAckParam{CurrentUserID: uuid.NewString()})
+	assert.NoError(t, err)
+
+	assert.Len(t, publisher.events, 2)
+	assert.Equal(t, []uuid.UUID{sender}, publisher.events[0].Recipients)
+	assert.Len(t, publisher.events[0].Data.(chat.MessageStatusBatch).Messages, 2)
+	assert.Equal(t, []uuid.UUID{other}, publisher.events[1].Recipients)
+	assert.Len(t, publisher.events[1].Data.(chat.MessageStatusBatch).Messages, 1)
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// retentionRepository keeps the expired message count of each room in memory,
+// the other repository methods are not used by the sweeper.
+type retentionRepository struct {
+	chat.IChatRepository
+	rooms   []chat.Room
+	expired map[uuid.UUID]int64
+	batches int
+}
+
+func (repo *retentionRepository) GetRoomsWithRetention(ctx context.Context) ([]chat.Room, error) {
+	return repo.rooms, nil
+}
+
+func (repo *retentionRepository) CountMessagesBefore(ctx context.Context, roomID string, before time.Time) (int64, error) {
+	return repo.expired[uuid.MustParse(roomID)], nil
+}
+
+func (repo *retentionRepository) DeleteMessagesBefore(ctx context.Context, roomID string, before time.Time, limit int) (int64, error) {
+	repo.batches++
+	id := uuid.MustParse(roomID)
+	deleted := min(repo.expired[id], int64(limit))
+	repo.expired[id] -= deleted
+	return deleted, nil
+}
+
+func TestRetentionSweeper(t *testing.T) {
+	days := 90
+	busy := chat.Room{ID: uuid.New(), RetentionDays: &days}
+	quiet := chat.Room{ID: uuid.New(), RetentionDays: &days}
+	now := time.Now()
+
+	t.Run("purges in batches", func(t *testing.T) {
+		repo := &retentionRepository{
+			rooms:   []chat.Room{busy, quiet},
+			expired: map[uuid.UUID]int64{busy.ID: 25},
+		}
+
+		reports, err := usecases.NewRetentionSweeper(repo, 10, false).Sweep(context.Background(), now)
+		assert.NoError(t, err)
+		assert.Len(t, reports, 1)
+		assert.Equal(t, busy.ID, reports[0].RoomID)
+		assert.Equal(t, int64(25), reports[0].Messages)
+		assert.Equal(t, now.AddDate(0, 0, -90), reports[0].Before)
+		assert.Equal(t, int64(0), repo.expired[busy.ID])
+		// three batches for the busy room and one for the quiet one
+		assert.Equal(t, 4, repo.batches)
+	})
+
+	t.Run("dry run only counts", func(t *testing.T) {
+		repo := &retentionRepository{
+			rooms:   []chat.Room{busy},
+			expired: map[uuid.UUID]int64{busy.ID: 25},
+		}
+
+		reports, err := usecases.NewRetentionSweeper(repo, 10, true).Sweep(context.Background(), now)
+		assert.NoError(t, err)
+		assert.Len(t, reports, 1)
+		assert.True(t, reports[0].DryRun)
+		assert.Equal(t, int64(25), reports[0].Messages)
+		assert.Equal(t, int64(25), repo.expired[busy.ID])
+		assert.Equal(t, 0, repo.batches)
+	})
+}
@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomPolicy(t *testing.T) {
+	t.Run("every member reads and writes", func(t *testing.T) {
+		for _, role := range []string{chat.RoomRoleMember, chat.RoomRoleAdmin, chat.RoomRoleOwner} {
+			assert.True(t, usecases.RoleAllows(role, usecases.RoomActionRead))
+			assert.True(t, usecases.RoleAllows(role, usecases.RoomActionWrite))
+		}
+	})
+
+	t.Run("only owners manage roles", func(t *testing.T) {
+		assert.False(t, usecases.RoleAllows(chat.RoomRoleMember, usecases.RoomActionManageRoles))
+		assert.False(t, usecases.RoleAllows(chat.RoomRoleAdmin, usecases.RoomActionManageRoles))
+		assert.True(t, usecases.RoleAllows(chat.RoomRoleOwner, usecases.RoomActionManageRoles))
+	})
+
+	t.Run("admins manage the room, its invites and members", func(t *testing.T) {
+		for _, action := range []usecases.RoomAction{usecases.RoomActionManageRoom, usecases.RoomActionManageInvites, usecases.RoomActionManageMembers, usecases.RoomActionViewRevisions, usecases.RoomActionModerateMessages, usecases.RoomActionPurgeMessages} {
+			assert.False(t, usecases.RoleAllows(chat.RoomRoleMember, action))
+			assert.True(t, usecases.RoleAllows(chat.RoomRoleAdmin, action))
+			assert.True(t, usecases.RoleAllows(chat.RoomRoleOwner, action))
+		}
+	})
+
+	t.Run("admins only remove lower roles", func(t *testing.T) {
+		assert.True(t, usecases.Outranks(chat.RoomRoleAdmin, chat.RoomRoleMember))
+		assert.False(t, usecases.Outranks(chat.RoomRoleAdmin, chat.RoomRoleAdmin))
+		assert.False(t, usecases.Outranks(chat.RoomRoleAdmin, chat.RoomRoleOwner))
+		assert.True(t, usecases.Outranks(chat.RoomRoleOwner, chat.RoomRoleAdmin))
+	})
+
+	t.Run("unknown role or action", func(t *testing.T) {
+		assert.False(t, usecases.RoleAllows("guest", usecases.RoomActionRead))
+		assert.False(t, usecases.RoleAllows(chat.RoomRoleOwner, usecases.RoomAction("fly")))
+	})
+}
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	chatDeliveryHttp "github.com/fikrihkll/chat-app/application/chat/delivery/http"
+	"github.com/fikrihkll/chat-app/application/chat/realtime"
+	"github.com/fikrihkll/chat-app/application/chat/repositories"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/fikrihkll/chat-app/infrastructure"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamRepository holds the messages of a single room, oldest first.
+type streamRepository struct {
+	chat.IChatRepository
+	messages []chat.Message
+}
+
+func (repo *streamRepository) GetRoomMember(ctx context.Context, roomID string, userEmail string) (chat.RoomMember, error) {
+	return chat.RoomMember{Role: chat.RoomRoleMember}, nil
+}
+
+func (repo *streamRepository) GetMessageByID(ctx context.Context, messageID string) (chat.Message, error) {
+	for _, message := range repo.messages {
+		if message.ID.String() == messageID {
+			return message, nil
+		}
+	}
+	return chat.Message{}, sql.ErrNoRows
+}
+
+func (repo *streamRepository) GetMessagesAfterID(ctx context.Context, roomID string, messageID string, limit int) ([]chat.Message, error) {
+	for i, message := range repo.messages {
+		if message.ID.String() == messageID {
+			after := repo.messages[i+1:]
+			return after[:min(len(after), limit)], nil
+		}
+	}
+	return nil, nil
+}
+
+func (repo *streamRepository) GetLatestMessageID(ctx context.Context, roomID string) (uuid.UUID, error) {
+	if len(repo.messages) == 0 {
+		return uuid.UUID{}, sql.ErrNoRows
+	}
+	return repo.messages[len(repo.messages)-1].ID, nil
+}
+
+func (repo *streamRepository) post(roomID uuid.UUID, count int) {
+	for range count {
+		repo.messages = append(repo.messages, chat.Message{ID: uuid.New(), RoomID: roomID})
+	}
+}
+
+func TestGetMissedMessages(t *testing.T) {
+	roomID := uuid.New()
+
+	for name, test := range map[string]struct {
+		missed   int
+		known    bool
+		replayed int
+		reason   string
+	}{
+		"a few missed":       {3, true, 3, ""},
+		"too many missed":    {1000, true, 0, chat.StreamResetTooManyMissed},
+		"unknown last event": {3, false, 0, chat.StreamResetUnknownLastEvent},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := &streamRepository{}
+			repo.post(roomID, 1)
+			lastEventID := repo.messages[0].ID.String()
+			if !test.known {
+				lastEventID = uuid.NewString()
+			}
+			repo.post(roomID, test.missed)
+			uc := usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+
+			messages, reset, err := uc.GetMissedMessages(context.Background(), chat.RoomEventsParam{
+				RoomID:           roomID.String(),
+				CurrentUserEmail: "member@mail.com",
+				LastEventID:      lastEventID,
+			})
+			assert.NoError(t, err)
+			assert.Len(t, messages, test.replayed)
+
+			if test.reason == "" {
+				assert.Nil(t, reset)
+				return
+			}
+			assert.Equal(t, test.reason, reset.Reason)
+			assert.Equal(t, repo.messages[len(repo.messages)-1].ID, *reset.LastMessageID)
+		})
+	}
+
+	t.Run("first connection", func(t *testing.T) {
+		repo := &streamRepository{}
+		repo.post(roomID, 3)
+		uc := usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+
+		messages, reset, err := uc.GetMissedMessages(context.Background(), chat.RoomEventsParam{
+			RoomID:           roomID.String(),
+			CurrentUserEmail: "member@mail.com",
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+		assert.Nil(t, reset)
+	})
+}
+
+func TestStreamRoomEvents(t *testing.T) {
+	me := chat.User{ID: uuid.New(), Email: "member@mail.com"}
+	roomID := uuid.New()
+	presenceRepo := &presenceRepository{users: []chat.User{me}}
+
+	// stream connects with lastEventID, publishes events once subscribed and
+	// returns the ids and event types written before it disconnects.
+	stream := func(repo *streamRepository, hub *realtime.Hub, lastEventID string, events ...chat.Event) (ids []string, types []string) {
+		handler := chatDeliveryHttp.NewChatHttpApi(
+			usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute),
+			nil,
+			usecases.NewPresenceApplication(repositories.NewPresenceStoreKeyValue(infrastructure.NewMemorySharedState()), presenceRepo, presenceRepo, &recordingPublisher{}),
+			nil,
+			hub,
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/chat/rooms/"+roomID.String()+"/events", nil).WithContext(ctx)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("room_id")
+		c.SetParamValues(roomID.String())
+		c.Set("id", me.ID.String())
+		c.Set("email", me.Email)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.NoError(t, handler.StreamRoomEvents(c))
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		for _, event := range events {
+			hub.Publish(context.Background(), event)
+		}
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-done
+
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			if id, ok := strings.CutPrefix(line, "id: "); ok {
+				ids = append(ids, id)
+			}
+			if eventType, ok := strings.CutPrefix(line, "event: "); ok {
+				types = append(types, eventType)
+			}
+		}
+		return
+	}
+
+	t.Run("reconnecting after a typing event replays", func(t *testing.T) {
+		repo := &streamRepository{}
+		repo.post(roomID, 2)
+		hub := realtime.NewHub(16)
+
+		// the message is both replayed and published, it is written once
+		message := chat.NewMessageCreatedEvent(repo.messages[1], []uuid.UUID{me.ID})
+		typing := chat.Event{ID: uuid.NewString(), Type: chat.EventTypingStarted, RoomID: roomID, CreatedAt: time.Now(), Recipients: []uuid.UUID{me.ID}}
+		ids, types := stream(repo, hub, repo.messages[0].ID.String(), message, typing)
+		assert.Equal(t, []string{chat.EventMessageCreated, chat.EventTypingStarted}, types)
+		assert.Equal(t, []string{message.ID}, ids)
+
+		repo.post(roomID, 1)
+		ids, types = stream(repo, hub, ids[len(ids)-1])
+		assert.Equal(t, []string{chat.EventMessageCreated}, types)
+		assert.Equal(t, []string{repo.messages[2].ID.String()}, ids)
+	})
+
+	t.Run("reset in an empty room has no id", func(t *testing.T) {
+		ids, types := stream(&streamRepository{}, realtime.NewHub(16), uuid.NewString())
+		assert.Equal(t, []string{chat.EventStreamReset}, types)
+		assert.Empty(t, ids)
+	})
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/repositories"
+	"github.com/fikrihkll/chat-app/config"
+	"github.com/fikrihkll/chat-app/infrastructure"
+	"github.com/google/uuid"
+	"github.com/jaswdr/faker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreadRetention(t *testing.T) {
+	cfg := config.Load("../../../test.env")
+
+	pgConn, err := infrastructure.NewPgConnection(cfg)
+	if err != nil {
+		t.Skip("postgres is not reachable:", err)
+	}
+
+	repo := repositories.NewChatRepositoryPostgree(pgConn)
+	ctx := context.Background()
+	fake := faker.New()
+
+	newUser := func() (id string, email string) {
+		email = fake.Person().Contact().Email
+		err := pgConn.QueryRowContext(ctx, "INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id",
+			fake.Person().Name(), email, fake.Internet().Password()).Scan(&id)
+		assert.NoError(t, err)
+		return
+	}
+	ownerID, ownerEmail := newUser()
+	memberID, memberEmail := newUser()
+
+	// newThread posts a root by the owner with one old and one recent reply
+	// by the member, the root and the old reply are a year old.
+	newThread := func(roomID string) (root chat.Message, oldReply chat.Message, recentReply chat.Message) {
+		root, err := repo.InsertMessageByRoomID(ctx, chat.NewMessageByRoomIDParam{CurrentUserID: ownerID, RoomID: roomID, Message: "root"})
+		assert.NoError(t, err)
+		oldReply, err = repo.InsertThreadReply(ctx, roomID, root.ID.String(), memberID, "old reply", "")
+		assert.NoError(t, err)
+		recentReply, err = repo.InsertThreadReply(ctx, roomID, root.ID.String(), memberID, "recent reply", "")
+		assert.NoError(t, err)
+
+		_, err = pgConn.ExecContext(ctx, "UPDATE messages SET created_at = NOW() - INTERVAL '1 year' WHERE id = ANY(ARRAY[$1, $2]::uuid[])", root.ID, oldReply.ID)
+		assert.NoError(t, err)
+		return
+	}
+
+	t.Run("retention keeps recent replies and empties their root", func(t *testing.T) {
+		room, err := repo.InsertRoom(ctx, fake.App().Name(), chat.RoomVisibilityPrivate, ownerEmail, []string{memberEmail})
+		assert.NoError(t, err)
+		root, oldReply, recentReply := newThread(room.ID.String())
+
+		before := time.Now().Add(-24 * time.Hour)
+		count, err := repo.CountMessagesBefore(ctx, room.ID.String(), before)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		deleted, err := repo.DeleteMessagesBefore(ctx, room.ID.String(), before, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		tombstone, err := repo.GetMessageByID(ctx, root.ID.String())
+		assert.NoError(t, err)
+		assert.NotNil(t, tombstone.DeletedAt)
+		assert.Empty(t, tombstone.Content)
+
+		_, err = repo.GetMessageByID(ctx, oldReply.ID.String())
+		assert.Error(t, err)
+		_, err = repo.GetMessageByID(ctx, recentReply.ID.String())
+		assert.NoError(t, err)
+
+		// a second sweep has nothing left to do
+		deleted, err = repo.DeleteMessagesBefore(ctx, room.ID.String(), before, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), deleted)
+	})
+
+	t.Run("purge reports the replies of a root", func(t *testing.T) {
+		room, err := repo.InsertRoom(ctx, fake.App().Name(), chat.RoomVisibilityPrivate, ownerEmail, []string{memberEmail})
+		assert.NoError(t, err)
+		root, oldReply, recentReply := newThread(room.ID.String())
+
+		replyIDs, err := repo.PurgeMessage(ctx, root.ID.String())
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []uuid.UUID{oldReply.ID, recentReply.ID}, replyIDs)
+
+		_, err = repo.GetMessageByID(ctx, recentReply.ID.String())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/application/chat/usecases"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// threadRepository keeps messages in memory and records where replies go.
+type threadRepository struct {
+	chat.IChatRepository
+	messages  map[string]chat.Message
+	repliedTo string
+}
+
+func (repo *threadRepository) GetMessageByID(ctx context.Context, messageID string) (chat.Message, error) {
+	message, ok := repo.messages[messageID]
+	if !ok {
+		return chat.Message{}, sql.ErrNoRows
+	}
+	return message, nil
+}
+
+func (repo *threadRepository) GetRoomMember(ctx context.Context, roomID string, userEmail string) (chat.RoomMember, error) {
+	return chat.RoomMember{Role: chat.RoomRoleMember}, nil
+}
+
+func (repo *threadRepository) GetRoomByID(ctx context.Context, roomID string) (chat.Room, error) {
+	return chat.Room{ID: uuid.MustParse(roomID)}, nil
+}
+
+func (repo *threadRepository) GetRoomMembers(ctx context.Context, roomID string) ([]chat.User, error) {
+	return nil, nil
+}
+
+func (repo *threadRepository) InsertThreadReply(ctx context.Context, roomID string, parentMessageID string, userID string, content string, replyToMessageID string) (chat.Message, error) {
+	repo.repliedTo = parentMessageID
+	parentID := uuid.MustParse(parentMessageID)
+	return chat.Message{ID: uuid.New(), RoomID: uuid.MustParse(roomID), ParentMessageID: &parentID, Content: content}, nil
+}
+
+func TestReplyInThread(t *testing.T) {
+	roomID := uuid.New()
+	root := chat.Message{ID: uuid.New(), RoomID: roomID}
+	reply := chat.Message{ID: uuid.New(), RoomID: roomID, ParentMessageID: &root.ID}
+
+	repo := &threadRepository{messages: map[string]chat.Message{
+		root.ID.String():  root,
+		reply.ID.String(): reply,
+	}}
+	uc := usecases.NewChatApplication(repo, nil, &recordingPublisher{}, nil, time.Minute)
+
+	for name, parent := range map[string]chat.Message{"to the root": root, "to a reply": reply} {
+		t.Run(name, func(t *testing.T) {
+			message, err := uc.ReplyInThread(context.Background(), chat.ThreadReplyParam{
+				CurrentUserID:    uuid.NewString(),
+				CurrentUserEmail: "member@mail.com",
+				ParentMessageID:  parent.ID.String(),
+				Content:          "hi",
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, root.ID.String(), repo.repliedTo)
+			assert.Equal(t, root.ID, *message.ParentMessageID)
+		})
+	}
+
+	t.Run("unknown message", func(t *testing.T) {
+		_, err := uc.ReplyInThread(context.Background(), chat.ThreadReplyParam{ParentMessageID: uuid.NewString()})
+		assert.ErrorIs(t, err, usecases.ErrMessageNotFound)
+	})
+}
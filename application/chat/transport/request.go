@@ -1,13 +1,17 @@
 package transport
 
 import (
+	"regexp"
+
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
 
+// ReplyTo optionally quotes a message of the same conversation.
 type NewMessageByEmail struct {
 	MemberEmail string `json:"member_email"`
 	Message     string `json:"message"`
+	ReplyTo     string `json:"reply_to"`
 }
 
 func (request NewMessageByEmail) Validate() error {
@@ -15,17 +19,60 @@ func (request NewMessageByEmail) Validate() error {
 		&request,
 		validation.Field(&request.Message, validation.Required),
 		validation.Field(&request.MemberEmail, validation.Required, is.Email),
+		validation.Field(&request.ReplyTo, is.UUID),
 	)
 }
 
+// ReplyTo optionally quotes a message of the same room.
 type NewMessageByRoomID struct {
-	Message     string `json:"message"`
+	Message string `json:"message"`
+	ReplyTo string `json:"reply_to"`
 }
 
 func (request NewMessageByRoomID) Validate() error {
 	return validation.ValidateStruct(
 		&request,
 		validation.Field(&request.Message, validation.Required),
+		validation.Field(&request.ReplyTo, is.UUID),
+	)
+}
+
+type MarkRead struct {
+	MessageID string `json:"message_id"`
+}
+
+func (request MarkRead) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.MessageID, validation.Required, is.UUID),
+	)
+}
+
+type DeliveryAck struct {
+	MessageIDs []string `json:"message_ids"`
+}
+
+func (request DeliveryAck) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.MessageIDs, validation.Required, validation.Length(1, 100), validation.Each(is.UUID)),
+	)
+}
+
+// NewRoom is private unless Visibility says otherwise, public channels can
+// be created without members.
+type NewRoom struct {
+	Name         string   `json:"name"`
+	Visibility   string   `json:"visibility"`
+	MemberEmails []string `json:"member_emails"`
+}
+
+func (request NewRoom) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Name, validation.Required, validation.Length(1, 250)),
+		validation.Field(&request.Visibility, validation.In("private", "public")),
+		validation.Field(&request.MemberEmails, validation.Length(0, 100), validation.Each(is.Email)),
 	)
 }
 
@@ -57,3 +104,148 @@ func (request Register) Validate() error {
 	)
 }
 
+type UpdatePresence struct {
+	Status string `json:"status"`
+}
+
+func (request UpdatePresence) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Status, validation.Required, validation.In("online", "away", "offline")),
+	)
+}
+
+type UpdateMemberRole struct {
+	Role string `json:"role"`
+}
+
+func (request UpdateMemberRole) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Role, validation.Required, validation.In("owner", "admin", "member")),
+	)
+}
+
+type NewInvite struct {
+	MaxUses        int `json:"max_uses"`
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+func (request NewInvite) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.MaxUses, validation.Required, validation.Min(1), validation.Max(1000)),
+		validation.Field(&request.ExpiresInHours, validation.Required, validation.Min(1), validation.Max(720)),
+	)
+}
+
+// UpdateRoom leaves the fields that are not sent as they are.
+type UpdateRoom struct {
+	Name        *string `json:"name"`
+	Topic       *string `json:"topic"`
+	Description *string `json:"description"`
+	AvatarURL   *string `json:"avatar_url"`
+	Visibility  *string `json:"visibility"`
+	// RetentionDays set to 0 keeps the messages forever
+	RetentionDays *int  `json:"retention_days"`
+	Archived      *bool `json:"archived"`
+}
+
+func (request UpdateRoom) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Name, validation.NilOrNotEmpty, validation.Length(1, 250)),
+		validation.Field(&request.Topic, validation.Length(0, 250)),
+		validation.Field(&request.Description, validation.Length(0, 2000)),
+		validation.Field(&request.AvatarURL, validation.Length(0, 500)),
+		validation.Field(&request.Visibility, validation.NilOrNotEmpty, validation.In("private", "public")),
+		validation.Field(&request.RetentionDays, validation.Min(0), validation.Max(3650)),
+	)
+}
+
+type ChannelDirectory struct {
+	Query  string `query:"q"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
+
+func (request ChannelDirectory) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Query, validation.Length(0, 100)),
+		validation.Field(&request.Limit, validation.Min(0), validation.Max(100)),
+		validation.Field(&request.Offset, validation.Min(0)),
+	)
+}
+
+// UpdateNotifications mutes the room until further notice when Muted comes
+// without MuteForMinutes.
+type UpdateNotifications struct {
+	Level          *string `json:"level"`
+	Muted          *bool   `json:"muted"`
+	MuteForMinutes *int    `json:"mute_for_minutes"`
+}
+
+func (request UpdateNotifications) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Level, validation.NilOrNotEmpty, validation.In("all", "mentions", "none")),
+		validation.Field(&request.MuteForMinutes,
+			validation.When(request.Muted == nil || !*request.Muted, validation.Nil.Error("can only be set when muting")),
+			validation.Min(1), validation.Max(525600),
+		),
+	)
+}
+
+type BanMember struct {
+	Reason string `json:"reason"`
+}
+
+func (request BanMember) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Reason, validation.Length(0, 500)),
+	)
+}
+
+type EditMessage struct {
+	Message string `json:"message"`
+}
+
+func (request EditMessage) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Message, validation.Required),
+	)
+}
+
+// emojiElement is a pictograph or a keycap with its variation selectors,
+// skin tones and tag characters, flags are two regional indicators in a row.
+const emojiElement = `(?:[\x{00A9}\x{00AE}\x{203C}\x{2049}\x{2122}\x{2139}\x{2194}-\x{2199}\x{21A9}\x{21AA}\x{231A}-\x{23FF}\x{24C2}\x{25AA}-\x{27BF}\x{2934}\x{2935}\x{2B05}-\x{2B55}\x{3030}\x{303D}\x{3297}\x{3299}\x{1F000}-\x{1FAFF}]|[0-9#*]\x{FE0F}?\x{20E3})[\x{FE0F}\x{1F3FB}-\x{1F3FF}\x{E0020}-\x{E007F}]*`
+
+// emojiPattern accepts emoji sequences, joined by ZWJ or not, and :shortcode:
+var emojiPattern = regexp.MustCompile(`^(?::[a-z0-9_+-]+:|` + emojiElement + `(?:\x{200D}?` + emojiElement + `)*)$`)
+
+type Reaction struct {
+	Emoji string
+}
+
+func (request Reaction) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Emoji, validation.Required, validation.RuneLength(1, 32), validation.Match(emojiPattern).Error("must be an emoji or a :shortcode:")),
+	)
+}
+
+type ThreadPage struct {
+	Limit  int `query:"limit"`
+	Offset int `query:"offset"`
+}
+
+func (request ThreadPage) Validate() error {
+	return validation.ValidateStruct(
+		&request,
+		validation.Field(&request.Limit, validation.Min(0), validation.Max(100)),
+		validation.Field(&request.Offset, validation.Min(0)),
+	)
+}
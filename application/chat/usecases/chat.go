@@ -2,18 +2,59 @@ package usecases
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/fikrihkll/chat-app/application/chat"
 	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
 )
 
+const (
+	// maxReplayMessages caps how many missed messages a reconnecting stream gets
+	maxReplayMessages = 500
+
+	TypingIndicatorTTL = 5 * time.Second
+	typingRateLimit    = 5
+	typingRateWindow   = 10 * time.Second
+)
+
+var ErrNotRoomMember = errors.New("you are not a member of this room")
+var ErrTooManyTypingIndicators = errors.New("too many typing indicators, slow down")
+var ErrMessageNotInRoom = errors.New("message not found in this room")
+var ErrMessageNotFound = errors.New("message not found")
+var ErrRoomMemberNotFound = errors.New("room member not found")
+var ErrRoomTooSmall = errors.New("a room needs at least one other member")
+var ErrChangeOwnRole = errors.New("you cannot change your own role")
+var ErrBannedFromRoom = errors.New("you are banned from this room")
+var ErrLastOwner = errors.New("the last owner cannot leave, make someone else owner first")
+var ErrRemoveSelf = errors.New("you cannot remove or ban yourself, leave the room instead")
+var ErrBanNotFound = errors.New("ban not found")
+var ErrChannelNotFound = errors.New("channel not found")
+var ErrNotMessageSender = errors.New("you can only edit your own messages")
+var ErrMessageNotEditable = errors.New("this message cannot be edited")
+var ErrEditWindowExpired = errors.New("this message is too old to be edited")
+var ErrMessageDeleted = errors.New("this message has been deleted")
+var ErrReactionNotFound = errors.New("reaction not found")
+var ErrMessageNotInThread = errors.New("message is not a reply in this thread")
+var ErrQuoteNotInRoom = errors.New("quoted message is not in this room")
+
 type ChatApplication struct {
 	chatRepository chat.IChatRepository
 	userRepository chat.IUserRepository
+	eventPublisher chat.IEventPublisher
+	rateLimiter    chat.IRateLimiter
+	roomPolicy     *RoomPolicy
+	editWindow     time.Duration
 }
 
-func NewChatApplication(chatRepository chat.IChatRepository, userRepository chat.IUserRepository) chat.IChatUseCase {
-	return &ChatApplication{chatRepository, userRepository}
+// NewChatApplication lets senders edit their messages for editWindow after
+// sending them.
+func NewChatApplication(chatRepository chat.IChatRepository, userRepository chat.IUserRepository, eventPublisher chat.IEventPublisher, rateLimiter chat.IRateLimiter, editWindow time.Duration) chat.IChatUseCase {
+	return &ChatApplication{chatRepository, userRepository, eventPublisher, rateLimiter, NewRoomPolicy(chatRepository), editWindow}
 }
 
 func (uc *ChatApplication) SaveMessageByEmail(ctx context.Context, newMessage chat.NewMessageByEmailParam) (err error) {
@@ -23,21 +64,1278 @@ func (uc *ChatApplication) SaveMessageByEmail(ctx context.Context, newMessage ch
 		return
 	}
 
-	err = uc.chatRepository.InsertMessageByEmail(ctx, newMessage, targetUser)
+	if newMessage.ReplyToMessageID != "" {
+		quoted, errQuote := uc.quotedMessage(ctx, newMessage.ReplyToMessageID)
+		if errQuote != nil {
+			err = errQuote
+			return
+		}
+
+		// the quote has to come from the direct room of the two users
+		room, errRoom := uc.chatRepository.GetRoomByID(ctx, quoted.RoomID.String())
+		if errRoom != nil {
+			err = errRoom
+			return
+		}
+
+		if room.Kind != chat.RoomKindDirect || !slices.Contains(room.Users, newMessage.CurrentUserEmail) || !slices.Contains(room.Users, targetUser.Email) {
+			err = ErrQuoteNotInRoom
+			return
+		}
+	}
+
+	message, err := uc.chatRepository.InsertMessageByEmail(ctx, newMessage, targetUser)
+	if err != nil {
+		return
+	}
+
+	uc.publishMessage(ctx, message)
 	return
 }
 
 func (uc *ChatApplication) SaveMessageByRoomID(ctx context.Context, newMessage chat.NewMessageByRoomIDParam) (err error) {
-	err = uc.chatRepository.InsertMessageByRoomID(ctx, newMessage)
+	if _, err = uc.roomPolicy.Authorize(ctx, newMessage.RoomID, newMessage.CurrentUserEmail, RoomActionWrite); err != nil {
+		return
+	}
+
+	if newMessage.ReplyToMessageID != "" {
+		quoted, errQuote := uc.quotedMessage(ctx, newMessage.ReplyToMessageID)
+		if errQuote != nil {
+			err = errQuote
+			return
+		}
+
+		if quoted.RoomID.String() != newMessage.RoomID {
+			err = ErrQuoteNotInRoom
+			return
+		}
+	}
+
+	message, err := uc.chatRepository.InsertMessageByRoomID(ctx, newMessage)
+	if err != nil {
+		return
+	}
+
+	uc.publishMessage(ctx, message)
 	return
 }
 
 func (uc *ChatApplication) GetMessages(ctx context.Context, params chat.MessageHistoryParams) (messages []chat.Message, err error) {
+	// a direct room is read like any other room once it is found, leaving it
+	// ends the access to its history
+	if params.RoomID == "" {
+		if params.RoomID, err = uc.chatRepository.GetDirectRoomID(ctx, params.CurrentUserEmail, params.TargetEmail); err != nil {
+			return
+		}
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionRead); err != nil {
+		return
+	}
+
 	messages, err = uc.chatRepository.GetMessage(ctx, params)
+	if err != nil {
+		return
+	}
+
+	err = uc.attachReactions(ctx, messages, params.CurrentUserEmail)
+	return
+}
+
+// quotedMessage returns the message a new one quotes, tombstones cannot be
+// quoted. Unknown messages are reported like messages of another room so
+// other rooms do not leak.
+func (uc *ChatApplication) quotedMessage(ctx context.Context, messageID string) (quoted chat.Message, err error) {
+	quoted, err = uc.chatRepository.GetMessageByID(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrQuoteNotInRoom
+		}
+		return
+	}
+
+	if quoted.DeletedAt != nil {
+		err = ErrMessageDeleted
+	}
+
+	return
+}
+
+// attachReactions fills the reaction summaries of history messages as seen
+// by the user with the given email.
+func (uc *ChatApplication) attachReactions(ctx context.Context, messages []chat.Message, userEmail string) (err error) {
+	if len(messages) == 0 {
+		return
+	}
+
+	messageIDs := make([]string, len(messages))
+	for i, message := range messages {
+		messageIDs[i] = message.ID.String()
+	}
+
+	summaries, err := uc.chatRepository.GetReactionSummaries(ctx, messageIDs, userEmail)
+	if err != nil {
+		return
+	}
+
+	for i := range messages {
+		messages[i].Reactions = summaries[messages[i].ID.String()]
+	}
+
+	return
+}
+
+// ReplyInThread posts a reply under a root message. Replying to a reply puts
+// the new one in the same thread, threads do not nest.
+func (uc *ChatApplication) ReplyInThread(ctx context.Context, params chat.ThreadReplyParam) (message chat.Message, err error) {
+	root, err := uc.threadRoot(ctx, params.ParentMessageID)
+	if err != nil {
+		return
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, root.RoomID.String(), params.CurrentUserEmail, RoomActionWrite); err != nil {
+		return
+	}
+
+	if params.ReplyToMessageID != "" {
+		quoted, errQuote := uc.quotedMessage(ctx, params.ReplyToMessageID)
+		if errQuote != nil {
+			err = errQuote
+			return
+		}
+
+		if quoted.RoomID != root.RoomID {
+			err = ErrQuoteNotInRoom
+			return
+		}
+	}
+
+	message, err = uc.chatRepository.InsertThreadReply(ctx, root.RoomID.String(), root.ID.String(), params.CurrentUserID, params.Content, params.ReplyToMessageID)
+	if err != nil {
+		return
+	}
+
+	uc.publishMessage(ctx, message)
+	return
+}
+
+// GetThread returns a page of the replies under a root message, with how far
+// the current user read the thread.
+func (uc *ChatApplication) GetThread(ctx context.Context, params chat.ThreadParam) (thread chat.Thread, err error) {
+	root, err := uc.threadRoot(ctx, params.MessageID)
+	if err != nil {
+		return
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, root.RoomID.String(), params.CurrentUserEmail, RoomActionRead); err != nil {
+		return
+	}
+
+	replies, err := uc.chatRepository.GetThreadReplies(ctx, root.ID.String(), params.Limit, params.Offset)
+	if err != nil {
+		return
+	}
+
+	messages := append([]chat.Message{root}, replies...)
+	if err = uc.attachReactions(ctx, messages, params.CurrentUserEmail); err != nil {
+		return
+	}
+
+	thread.Root = messages[0]
+	thread.Replies = messages[1:]
+	thread.Total = root.ReplyCount
+	thread.Limit = params.Limit
+	thread.Offset = params.Offset
+
+	cursor, err := uc.chatRepository.GetThreadReadCursor(ctx, root.ID.String(), params.CurrentUserID)
+	switch {
+	case err == nil:
+		thread.ReadCursor = &cursor
+	case !errors.Is(err, sql.ErrNoRows):
+		return
+	}
+
+	thread.UnreadCount, err = uc.chatRepository.CountThreadUnread(ctx, root.ID.String(), params.CurrentUserID)
+	return
+}
+
+// MarkThreadRead moves the user's read cursor of a thread, the read cursor of
+// the room stays where it is.
+func (uc *ChatApplication) MarkThreadRead(ctx context.Context, params chat.MarkThreadReadParam) (cursor chat.ThreadReadCursor, err error) {
+	root, err := uc.threadRoot(ctx, params.ParentMessageID)
+	if err != nil {
+		return
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, root.RoomID.String(), params.CurrentUserEmail, RoomActionRead); err != nil {
+		return
+	}
+
+	message, err := uc.chatRepository.GetMessageByID(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotInThread
+		}
+		return
+	}
+
+	if message.ParentMessageID == nil || *message.ParentMessageID != root.ID {
+		err = ErrMessageNotInThread
+		return
+	}
+
+	cursor, advanced, err := uc.chatRepository.AdvanceThreadReadCursor(ctx, root.ID.String(), params.CurrentUserID, params.MessageID)
+	if err != nil {
+		return
+	}
+
+	if !advanced {
+		cursor, err = uc.chatRepository.GetThreadReadCursor(ctx, root.ID.String(), params.CurrentUserID)
+		return
+	}
+
+	messages, errReceipts := uc.chatRepository.MarkThreadMessagesRead(ctx, root.ID.String(), params.CurrentUserID, params.MessageID)
+	if errReceipts == nil {
+		uc.publishMessageStatuses(ctx, messages)
+	}
+
+	return
+}
+
+// threadRoot returns the root of the thread the message belongs to, the
+// message itself when it is not a reply.
+func (uc *ChatApplication) threadRoot(ctx context.Context, messageID string) (root chat.Message, err error) {
+	root, err = uc.chatRepository.GetMessageByID(ctx, messageID)
+	if err == nil && root.ParentMessageID != nil {
+		root, err = uc.chatRepository.GetMessageByID(ctx, root.ParentMessageID.String())
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrMessageNotFound
+	}
+
+	return
+}
+
+// CreateRoom starts a group conversation between the current user and the
+// given members, who must all be registered.
+func (uc *ChatApplication) CreateRoom(ctx context.Context, params chat.CreateRoomParam) (room chat.Room, err error) {
+	memberEmails := []string{params.CurrentUserEmail}
+
+	for _, email := range params.MemberEmails {
+		if slices.Contains(memberEmails, email) {
+			continue
+		}
+
+		if _, err = uc.userRepository.GetUserByEmail(ctx, email); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = fmt.Errorf("%w: %s", ErrRoomMemberNotFound, email)
+			}
+			return
+		}
+
+		memberEmails = append(memberEmails, email)
+	}
+
+	visibility := params.Visibility
+	if visibility == "" {
+		visibility = chat.RoomVisibilityPrivate
+	}
+
+	// public channels can start empty, people join them from the directory
+	if len(memberEmails) < 2 && visibility != chat.RoomVisibilityPublic {
+		err = ErrRoomTooSmall
+		return
+	}
+
+	room, err = uc.chatRepository.InsertRoom(ctx, params.Name, visibility, params.CurrentUserEmail, memberEmails)
+	return
+}
+
+func (uc *ChatApplication) GetRoomMemberships(ctx context.Context, params chat.RoomMembersParam) (members []chat.RoomMember, err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionRead); err != nil {
+		return
+	}
+
+	members, err = uc.chatRepository.GetRoomMemberships(ctx, params.RoomID)
+	return
+}
+
+// UpdateMemberRole lets an owner promote or demote another member. Owners
+// cannot change their own role so a room never loses its last owner that way.
+func (uc *ChatApplication) UpdateMemberRole(ctx context.Context, params chat.UpdateMemberRoleParam) (member chat.RoomMember, err error) {
+	current, err := uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageRoles)
+	if err != nil {
+		return
+	}
+
+	if current.UserID.String() == params.UserID {
+		err = ErrChangeOwnRole
+		return
+	}
+
+	member, err = uc.chatRepository.UpdateMemberRole(ctx, params.RoomID, params.UserID, params.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrRoomMemberNotFound
+	}
+
 	return
 }
 
 func (uc *ChatApplication) GetRoomsByID(ctx context.Context, currentUserEmail string) (rooms []chat.Room, err error) {
 	rooms, err = uc.chatRepository.GetRoomsByID(ctx, currentUserEmail)
 	return
-}
\ No newline at end of file
+}
+
+// GetMissedMessages replays the messages after the last event the stream
+// received. An event that is not a message of the room, or one purged since,
+// and more than maxReplayMessages missed get a reset instead.
+func (uc *ChatApplication) GetMissedMessages(ctx context.Context, params chat.RoomEventsParam) (messages []chat.Message, reset *chat.StreamReset, err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionRead); err != nil {
+		return
+	}
+
+	if params.LastEventID == "" {
+		return
+	}
+
+	last, err := uc.chatRepository.GetMessageByID(ctx, params.LastEventID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	if err != nil || last.RoomID.String() != params.RoomID {
+		reset, err = uc.streamReset(ctx, params.RoomID, chat.StreamResetUnknownLastEvent)
+		return
+	}
+
+	// one more than replayed tells whether some would be left out
+	messages, err = uc.chatRepository.GetMessagesAfterID(ctx, params.RoomID, params.LastEventID, maxReplayMessages+1)
+	if err != nil {
+		return
+	}
+
+	if len(messages) > maxReplayMessages {
+		messages = nil
+		reset, err = uc.streamReset(ctx, params.RoomID, chat.StreamResetTooManyMissed)
+	}
+
+	return
+}
+
+func (uc *ChatApplication) streamReset(ctx context.Context, roomID string, reason string) (reset *chat.StreamReset, err error) {
+	reset = &chat.StreamReset{RoomID: uuid.MustParse(roomID), Reason: reason}
+
+	lastMessageID, err := uc.chatRepository.GetLatestMessageID(ctx, roomID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		return
+	}
+
+	reset.LastMessageID = &lastMessageID
+	return
+}
+
+// SendTypingIndicator tells the other members that the user is typing. The
+// indicator is not stored anywhere and expires on the client side.
+func (uc *ChatApplication) SendTypingIndicator(ctx context.Context, params chat.TypingParam) (err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionWrite); err != nil {
+		return
+	}
+
+	allowed, err := uc.rateLimiter.Allow(ctx, "typing:"+params.CurrentUserID, typingRateLimit, typingRateWindow)
+	if err != nil {
+		return
+	}
+
+	if !allowed {
+		err = ErrTooManyTypingIndicators
+		return
+	}
+
+	roomID, err := uuid.Parse(params.RoomID)
+	if err != nil {
+		return
+	}
+
+	userID, err := uuid.Parse(params.CurrentUserID)
+	if err != nil {
+		return
+	}
+
+	recipients, err := roomRecipients(ctx, uc.chatRepository, params.RoomID, userID)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	err = uc.eventPublisher.Publish(ctx, chat.Event{
+		ID:     uuid.NewString(),
+		Type:   chat.EventTypingStarted,
+		RoomID: roomID,
+		Data: chat.TypingIndicator{
+			RoomID:    roomID,
+			UserID:    userID,
+			ExpiresAt: now.Add(TypingIndicatorTTL),
+		},
+		CreatedAt:  now,
+		Recipients: recipients,
+	})
+	return
+}
+
+// MarkRead moves the user's read cursor up to the given message and sends a
+// receipt to the room. Reading an older message than the one already read
+// leaves the cursor where it is.
+func (uc *ChatApplication) MarkRead(ctx context.Context, params chat.MarkReadParam) (cursor chat.ReadCursor, err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionRead); err != nil {
+		return
+	}
+
+	message, err := uc.chatRepository.GetMessageByID(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotInRoom
+		}
+		return
+	}
+
+	// thread replies are marked read through their thread
+	if message.RoomID.String() != params.RoomID || message.ParentMessageID != nil {
+		err = ErrMessageNotInRoom
+		return
+	}
+
+	previous, err := uc.chatRepository.GetReadCursor(ctx, params.RoomID, params.CurrentUserID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	cursor, advanced, err := uc.chatRepository.AdvanceReadCursor(ctx, params.RoomID, params.CurrentUserID, params.MessageID)
+	if err != nil {
+		return
+	}
+
+	if !advanced {
+		cursor, err = uc.chatRepository.GetReadCursor(ctx, params.RoomID, params.CurrentUserID)
+		return
+	}
+
+	uc.publishReadReceipt(ctx, cursor)
+
+	previousMessageID := ""
+	if previous.MessageID != uuid.Nil {
+		previousMessageID = previous.MessageID.String()
+	}
+
+	// the cursor is what counts for unread, receipts only feed message statuses
+	messages, errReceipts := uc.chatRepository.MarkMessagesRead(ctx, params.RoomID, params.CurrentUserID, previousMessageID, params.MessageID)
+	if errReceipts == nil {
+		uc.publishMessageStatuses(ctx, messages)
+	}
+
+	return
+}
+
+// AcknowledgeDelivery records that the messages reached one of the user's
+// devices. Messages of rooms the user is not in are ignored.
+func (uc *ChatApplication) AcknowledgeDelivery(ctx context.Context, params chat.DeliveryAckParam) (err error) {
+	messages, err := uc.chatRepository.MarkMessagesDelivered(ctx, params.CurrentUserID, params.MessageIDs)
+	if err != nil {
+		return
+	}
+
+	uc.publishMessageStatuses(ctx, messages)
+	return
+}
+
+func (uc *ChatApplication) GetMessageReceipts(ctx context.Context, params chat.MessageReceiptsParam) (receipts []chat.MessageReceipt, err error) {
+	message, err := uc.chatRepository.GetMessageByID(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotFound
+		}
+		return
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, message.RoomID.String(), params.CurrentUserEmail, RoomActionRead); err != nil {
+		return
+	}
+
+	receipts, err = uc.chatRepository.GetMessageReceipts(ctx, params.MessageID)
+	return
+}
+
+// EditMessage replaces the content of a message the current user sent, the
+// previous content is kept as a revision. Archived rooms refuse edits.
+func (uc *ChatApplication) EditMessage(ctx context.Context, params chat.EditMessageParam) (message chat.Message, err error) {
+	message, err = uc.chatRepository.GetMessageByID(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotFound
+		}
+		return
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, message.RoomID.String(), params.CurrentUserEmail, RoomActionWrite); err != nil {
+		return
+	}
+
+	switch {
+	case message.Kind != chat.MessageKindUser, message.DeletedAt != nil:
+		err = ErrMessageNotEditable
+		return
+	case message.UserID.String() != params.CurrentUserID:
+		err = ErrNotMessageSender
+		return
+	case message.Content == params.Content:
+		return
+	}
+
+	// the window is checked by the repository against the database clock
+	message, err = uc.chatRepository.UpdateMessageContent(ctx, params.MessageID, params.CurrentUserID, params.Content, uc.editWindow)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrEditWindowExpired
+		}
+		return
+	}
+
+	uc.publishMessageChange(ctx, chat.EventMessageUpdated, message, message.UpdatedAt)
+	return
+}
+
+// DeleteMessage leaves a tombstone in place of the message. Senders delete
+// their own messages, admins and owners anyone's. Deleting a tombstone again
+// changes nothing.
+func (uc *ChatApplication) DeleteMessage(ctx context.Context, params chat.DeleteMessageParam) (message chat.Message, err error) {
+	message, err = uc.chatRepository.GetMessageByID(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotFound
+		}
+		return
+	}
+
+	action := RoomActionModerateMessages
+	if message.UserID.String() == params.CurrentUserID {
+		action = RoomActionWrite
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, message.RoomID.String(), params.CurrentUserEmail, action); err != nil {
+		return
+	}
+
+	if message.DeletedAt != nil {
+		return
+	}
+
+	message, err = uc.chatRepository.DeleteMessageContent(ctx, params.MessageID, params.CurrentUserID)
+	if err != nil {
+		return
+	}
+
+	uc.publishMessageChange(ctx, chat.EventMessageDeleted, message, *message.DeletedAt)
+	return
+}
+
+// AddReaction reacts to a message with an emoji, reacting again with the same
+// emoji is a no-op and is not broadcast.
+func (uc *ChatApplication) AddReaction(ctx context.Context, params chat.ReactionParam) (reaction chat.MessageReaction, err error) {
+	message, err := uc.reactableMessage(ctx, params)
+	if err != nil {
+		return
+	}
+
+	reaction, added, err := uc.chatRepository.InsertReaction(ctx, message.ID.String(), params.CurrentUserID, params.Emoji)
+	if err != nil || !added {
+		return
+	}
+
+	uc.publishReaction(ctx, chat.EventReactionAdded, reaction)
+	return
+}
+
+func (uc *ChatApplication) RemoveReaction(ctx context.Context, params chat.ReactionParam) (err error) {
+	message, err := uc.reactableMessage(ctx, params)
+	if err != nil {
+		return
+	}
+
+	if err = uc.chatRepository.DeleteReaction(ctx, message.ID.String(), params.CurrentUserID, params.Emoji); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrReactionNotFound
+		}
+		return
+	}
+
+	userID, err := uuid.Parse(params.CurrentUserID)
+	if err != nil {
+		return
+	}
+
+	uc.publishReaction(ctx, chat.EventReactionRemoved, chat.MessageReaction{
+		MessageID: message.ID,
+		RoomID:    message.RoomID,
+		UserID:    userID,
+		Emoji:     params.Emoji,
+		CreatedAt: time.Now(),
+	})
+	return
+}
+
+// reactableMessage returns the message the member wants to react to, reacting
+// needs write access and is not possible on tombstones.
+func (uc *ChatApplication) reactableMessage(ctx context.Context, params chat.ReactionParam) (message chat.Message, err error) {
+	message, err = uc.chatRepository.GetMessageByID(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotFound
+		}
+		return
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, message.RoomID.String(), params.CurrentUserEmail, RoomActionWrite); err != nil {
+		return
+	}
+
+	if message.DeletedAt != nil {
+		err = ErrMessageDeleted
+	}
+
+	return
+}
+
+// publishReaction tells the room about a reaction change, counts are left to
+// the clients. The change is already saved, so a failure is only logged.
+func (uc *ChatApplication) publishReaction(ctx context.Context, eventType string, reaction chat.MessageReaction) {
+	recipients, err := roomRecipients(ctx, uc.chatRepository, reaction.RoomID.String())
+	if err != nil {
+		return
+	}
+
+	if err := uc.eventPublisher.Publish(ctx, chat.Event{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		RoomID:     reaction.RoomID,
+		Data:       reaction,
+		CreatedAt:  reaction.CreatedAt,
+		Recipients: recipients,
+	}); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
+
+// PurgeMessage removes a message from history without leaving a tombstone,
+// for the removals the law asks for. Only admins and owners can purge.
+func (uc *ChatApplication) PurgeMessage(ctx context.Context, params chat.DeleteMessageParam) (purged chat.PurgedMessage, err error) {
+	message, err := uc.chatRepository.GetMessageByID(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotFound
+		}
+		return
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, message.RoomID.String(), params.CurrentUserEmail, RoomActionPurgeMessages); err != nil {
+		return
+	}
+
+	replyIDs, err := uc.chatRepository.PurgeMessage(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotFound
+		}
+		return
+	}
+
+	common.Log(common.LOG_LEVEL_INFO, fmt.Sprintf("message %s of room %s purged by %s with %d thread replies", message.ID, message.RoomID, params.CurrentUserID, len(replyIDs)))
+
+	purged = chat.PurgedMessage{
+		ID:       message.ID,
+		RoomID:   message.RoomID,
+		PurgedAt: time.Now(),
+		ReplyIDs: replyIDs,
+	}
+
+	recipients, err := roomRecipients(ctx, uc.chatRepository, message.RoomID.String())
+	if err != nil {
+		// the message is already gone
+		err = nil
+		return
+	}
+
+	if errPublish := uc.eventPublisher.Publish(ctx, chat.Event{
+		ID:         uuid.NewString(),
+		Type:       chat.EventMessagePurged,
+		RoomID:     message.RoomID,
+		Data:       purged,
+		CreatedAt:  purged.PurgedAt,
+		Recipients: recipients,
+	}); errPublish != nil {
+		common.Log(common.LOG_LEVEL_ERROR, errPublish.Error())
+	}
+
+	return
+}
+
+// GetMessageRevisions lists the previous versions of a message, oldest
+// first. Only room admins and owners can read them.
+func (uc *ChatApplication) GetMessageRevisions(ctx context.Context, params chat.MessageRevisionsParam) (revisions []chat.MessageRevision, err error) {
+	message, err := uc.chatRepository.GetMessageByID(ctx, params.MessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrMessageNotFound
+		}
+		return
+	}
+
+	if _, err = uc.roomPolicy.Authorize(ctx, message.RoomID.String(), params.CurrentUserEmail, RoomActionViewRevisions); err != nil {
+		return
+	}
+
+	revisions, err = uc.chatRepository.GetMessageRevisions(ctx, params.MessageID)
+	return
+}
+
+// roomRecipients lists the room members an event goes to, except the given users.
+func roomRecipients(ctx context.Context, chatRepository chat.IChatRepository, roomID string, except ...uuid.UUID) (recipients []uuid.UUID, err error) {
+	members, err := chatRepository.GetRoomMembers(ctx, roomID)
+	if err != nil {
+		return
+	}
+
+	recipients = make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		if !slices.Contains(except, member.ID) {
+			recipients = append(recipients, member.ID)
+		}
+	}
+
+	return
+}
+
+// publishMessage pushes a saved message to the room members. The message is
+// already persisted at this point, so a failure is only logged.
+func (uc *ChatApplication) publishMessage(ctx context.Context, message chat.Message) {
+	recipients, err := roomRecipients(ctx, uc.chatRepository, message.RoomID.String())
+	if err != nil {
+		return
+	}
+
+	if err := uc.eventPublisher.Publish(ctx, chat.NewMessageCreatedEvent(message, recipients)); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
+
+// UpdateRoom applies the settings that changed and posts a system message
+// for each of them so members see who changed what.
+func (uc *ChatApplication) UpdateRoom(ctx context.Context, params chat.UpdateRoomParam) (room chat.Room, err error) {
+	member, err := uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageRoom)
+	if err != nil {
+		return
+	}
+
+	room, err = uc.chatRepository.GetRoomByID(ctx, params.RoomID)
+	if err != nil {
+		return
+	}
+
+	var changes []string
+
+	if params.Name != nil && *params.Name != room.Name {
+		room.Name = *params.Name
+		changes = append(changes, fmt.Sprintf("%s renamed the room to %q", member.Name, room.Name))
+	}
+
+	if params.Topic != nil && *params.Topic != room.Topic {
+		room.Topic = *params.Topic
+		changes = append(changes, fmt.Sprintf("%s changed the topic to %q", member.Name, room.Topic))
+	}
+
+	if params.Description != nil && *params.Description != room.Description {
+		room.Description = *params.Description
+		changes = append(changes, fmt.Sprintf("%s changed the description", member.Name))
+	}
+
+	if params.AvatarURL != nil && *params.AvatarURL != room.AvatarURL {
+		room.AvatarURL = *params.AvatarURL
+		changes = append(changes, fmt.Sprintf("%s changed the avatar", member.Name))
+	}
+
+	if params.Visibility != nil && *params.Visibility != room.Visibility {
+		room.Visibility = *params.Visibility
+		changes = append(changes, fmt.Sprintf("%s made the room %s", member.Name, room.Visibility))
+	}
+
+	if params.RetentionDays != nil && *params.RetentionDays != retentionDays(room) {
+		if *params.RetentionDays == 0 {
+			room.RetentionDays = nil
+			changes = append(changes, fmt.Sprintf("%s turned off message retention", member.Name))
+		} else {
+			room.RetentionDays = params.RetentionDays
+			changes = append(changes, fmt.Sprintf("%s set messages to be deleted after %d days", member.Name, *room.RetentionDays))
+		}
+	}
+
+	if params.Archived != nil && *params.Archived != (room.ArchivedAt != nil) {
+		if *params.Archived {
+			now := time.Now()
+			room.ArchivedAt = &now
+			changes = append(changes, fmt.Sprintf("%s archived the room", member.Name))
+		} else {
+			room.ArchivedAt = nil
+			changes = append(changes, fmt.Sprintf("%s unarchived the room", member.Name))
+		}
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+
+	room, err = uc.chatRepository.UpdateRoom(ctx, room)
+	if err != nil {
+		return
+	}
+
+	uc.publishRoomUpdated(ctx, room)
+
+	for _, change := range changes {
+		uc.postSystemMessage(ctx, room.ID.String(), member.UserID.String(), change)
+	}
+
+	return
+}
+
+// LeaveRoom takes the current user out of the room. Owners can only leave
+// once someone else owns the room too, unless nobody else is left.
+func (uc *ChatApplication) LeaveRoom(ctx context.Context, params chat.RoomMembersParam) (err error) {
+	member, err := uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionRead)
+	if err != nil {
+		return
+	}
+
+	if member.Role == chat.RoomRoleOwner {
+		members, errMembers := uc.chatRepository.GetRoomMemberships(ctx, params.RoomID)
+		if errMembers != nil {
+			err = errMembers
+			return
+		}
+
+		owners := 0
+		for _, other := range members {
+			if other.Role == chat.RoomRoleOwner {
+				owners++
+			}
+		}
+
+		if owners == 1 && len(members) > 1 {
+			err = ErrLastOwner
+			return
+		}
+	}
+
+	err = uc.chatRepository.DeleteRoomMember(ctx, params.RoomID, member.UserID.String())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNotRoomMember
+		}
+		return
+	}
+
+	uc.postSystemMessage(ctx, params.RoomID, member.UserID.String(), fmt.Sprintf("%s left the room", member.Name))
+	uc.publishMemberRemoved(ctx, chat.MemberRemoval{
+		RoomID:    member.RoomID,
+		UserID:    member.UserID,
+		Name:      member.Name,
+		Email:     member.Email,
+		Reason:    chat.MemberRemovalLeft,
+		RemovedBy: member.UserID,
+		RemovedAt: time.Now(),
+	})
+
+	return
+}
+
+// RemoveMember takes someone else out of the room, they can come back with
+// an invite. Admins can only remove members with a lower role than theirs.
+func (uc *ChatApplication) RemoveMember(ctx context.Context, params chat.RoomMemberParam) (err error) {
+	current, err := uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageMembers)
+	if err != nil {
+		return
+	}
+
+	if current.UserID.String() == params.UserID {
+		err = ErrRemoveSelf
+		return
+	}
+
+	target, err := uc.targetMember(ctx, params.RoomID, params.UserID)
+	if err != nil {
+		return
+	}
+
+	if !Outranks(current.Role, target.Role) {
+		err = ErrRoomPermissionDenied
+		return
+	}
+
+	err = uc.chatRepository.DeleteRoomMember(ctx, params.RoomID, params.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrRoomMemberNotFound
+		}
+		return
+	}
+
+	uc.postSystemMessage(ctx, params.RoomID, current.UserID.String(), fmt.Sprintf("%s removed %s from the room", current.Name, target.Name))
+	uc.publishMemberRemoved(ctx, chat.MemberRemoval{
+		RoomID:    target.RoomID,
+		UserID:    target.UserID,
+		Name:      target.Name,
+		Email:     target.Email,
+		Reason:    chat.MemberRemovalRemoved,
+		RemovedBy: current.UserID,
+		RemovedAt: time.Now(),
+	})
+
+	return
+}
+
+// BanMember removes the user from the room if they are in it and keeps them
+// from coming back. Users who already left can be banned too.
+func (uc *ChatApplication) BanMember(ctx context.Context, params chat.BanMemberParam) (ban chat.RoomBan, err error) {
+	current, err := uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageMembers)
+	if err != nil {
+		return
+	}
+
+	if current.UserID.String() == params.UserID {
+		err = ErrRemoveSelf
+		return
+	}
+
+	user, err := uc.userRepository.GetUserByID(ctx, params.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = common.UserNotFoundError
+		}
+		return
+	}
+
+	target, err := uc.chatRepository.GetRoomMember(ctx, params.RoomID, user.Email)
+	wasMember := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	if wasMember && !Outranks(current.Role, target.Role) {
+		err = ErrRoomPermissionDenied
+		return
+	}
+
+	ban, err = uc.chatRepository.InsertRoomBan(ctx, chat.RoomBan{
+		RoomID:   current.RoomID,
+		UserID:   user.ID,
+		BannedBy: current.UserID,
+		Reason:   params.Reason,
+	})
+	if err != nil {
+		return
+	}
+
+	uc.postSystemMessage(ctx, params.RoomID, current.UserID.String(), fmt.Sprintf("%s banned %s from the room", current.Name, user.Name))
+
+	if wasMember {
+		uc.publishMemberRemoved(ctx, chat.MemberRemoval{
+			RoomID:    target.RoomID,
+			UserID:    target.UserID,
+			Name:      target.Name,
+			Email:     target.Email,
+			Reason:    chat.MemberRemovalBanned,
+			RemovedBy: current.UserID,
+			RemovedAt: ban.CreatedAt,
+		})
+	}
+
+	return
+}
+
+// UnbanMember lets the user join again, it does not add them back.
+func (uc *ChatApplication) UnbanMember(ctx context.Context, params chat.RoomMemberParam) (err error) {
+	current, err := uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageMembers)
+	if err != nil {
+		return
+	}
+
+	user, err := uc.userRepository.GetUserByID(ctx, params.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrBanNotFound
+		}
+		return
+	}
+
+	err = uc.chatRepository.DeleteRoomBan(ctx, params.RoomID, params.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrBanNotFound
+		}
+		return
+	}
+
+	uc.postSystemMessage(ctx, params.RoomID, current.UserID.String(), fmt.Sprintf("%s unbanned %s", current.Name, user.Name))
+	return
+}
+
+func (uc *ChatApplication) GetRoomBans(ctx context.Context, params chat.RoomMembersParam) (bans []chat.RoomBan, err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageMembers); err != nil {
+		return
+	}
+
+	bans, err = uc.chatRepository.GetRoomBans(ctx, params.RoomID)
+	return
+}
+
+func (uc *ChatApplication) GetChannelDirectory(ctx context.Context, params chat.ChannelDirectoryParam) (directory chat.ChannelDirectory, err error) {
+	directory.Channels, directory.Total, err = uc.chatRepository.SearchPublicRooms(ctx, params.CurrentUserID, params.Query, params.Limit, params.Offset)
+	if err != nil {
+		return
+	}
+
+	if directory.Channels == nil {
+		directory.Channels = []chat.PublicChannel{}
+	}
+	directory.Limit = params.Limit
+	directory.Offset = params.Offset
+
+	return
+}
+
+// JoinChannel adds the current user to a public channel. Private rooms are
+// reported as not found so their existence is not leaked.
+func (uc *ChatApplication) JoinChannel(ctx context.Context, params chat.JoinChannelParam) (member chat.RoomMember, err error) {
+	room, err := uc.chatRepository.GetRoomByID(ctx, params.RoomID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrChannelNotFound
+		}
+		return
+	}
+
+	if room.Visibility != chat.RoomVisibilityPublic {
+		err = ErrChannelNotFound
+		return
+	}
+
+	if room.ArchivedAt != nil {
+		err = ErrRoomArchived
+		return
+	}
+
+	isBanned, err := uc.chatRepository.IsBannedFromRoom(ctx, params.RoomID, params.CurrentUserID)
+	if err != nil {
+		return
+	}
+
+	if isBanned {
+		err = ErrBannedFromRoom
+		return
+	}
+
+	isMember, err := uc.chatRepository.IsRoomMember(ctx, params.RoomID, params.CurrentUserEmail)
+	if err != nil {
+		return
+	}
+
+	if isMember {
+		err = ErrAlreadyRoomMember
+		return
+	}
+
+	member, err = uc.chatRepository.InsertRoomMember(ctx, params.RoomID, params.CurrentUserID)
+	if err != nil {
+		return
+	}
+
+	publishMemberJoined(ctx, uc.chatRepository, uc.eventPublisher, member)
+	return
+}
+
+func (uc *ChatApplication) UpdateNotificationSettings(ctx context.Context, params chat.UpdateNotificationsParam) (settings chat.NotificationSettings, err error) {
+	member, err := uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionRead)
+	if err != nil {
+		return
+	}
+
+	var mutedUntil *time.Time
+	if params.Muted != nil && *params.Muted && params.MuteFor > 0 {
+		until := time.Now().Add(params.MuteFor)
+		mutedUntil = &until
+	}
+
+	settings, err = uc.chatRepository.UpdateNotificationSettings(ctx, params.RoomID, member.UserID.String(), params.Level, params.Muted, mutedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotRoomMember
+	}
+
+	return
+}
+
+// targetMember returns ErrRoomMemberNotFound when the user is not in the room.
+func (uc *ChatApplication) targetMember(ctx context.Context, roomID string, userID string) (member chat.RoomMember, err error) {
+	user, err := uc.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrRoomMemberNotFound
+		}
+		return
+	}
+
+	member, err = uc.chatRepository.GetRoomMember(ctx, roomID, user.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrRoomMemberNotFound
+	}
+
+	return
+}
+
+// postSystemMessage records a change in the room history. The change itself
+// is already saved, so a failure is only logged.
+func (uc *ChatApplication) postSystemMessage(ctx context.Context, roomID string, userID string, content string) {
+	message, err := uc.chatRepository.InsertSystemMessage(ctx, roomID, userID, content)
+	if err != nil {
+		return
+	}
+
+	uc.publishMessage(ctx, message)
+}
+
+func (uc *ChatApplication) publishRoomUpdated(ctx context.Context, room chat.Room) {
+	recipients, err := roomRecipients(ctx, uc.chatRepository, room.ID.String())
+	if err != nil {
+		return
+	}
+
+	if err := uc.eventPublisher.Publish(ctx, chat.Event{
+		ID:         uuid.NewString(),
+		Type:       chat.EventRoomUpdated,
+		RoomID:     room.ID,
+		Data:       room,
+		CreatedAt:  room.UpdatedAt,
+		Recipients: recipients,
+	}); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
+
+// publishMemberRemoved tells the remaining members who is gone. The removed
+// user hears about it too so their clients can drop the room, it is the last
+// event of the room they get.
+func (uc *ChatApplication) publishMemberRemoved(ctx context.Context, removal chat.MemberRemoval) {
+	recipients, err := roomRecipients(ctx, uc.chatRepository, removal.RoomID.String())
+	if err != nil {
+		return
+	}
+
+	if err := uc.eventPublisher.Publish(ctx, chat.Event{
+		ID:         uuid.NewString(),
+		Type:       chat.EventMemberRemoved,
+		RoomID:     removal.RoomID,
+		Data:       removal,
+		CreatedAt:  removal.RemovedAt,
+		Recipients: append(recipients, removal.UserID),
+	}); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
+
+// publishMessageChange sends the new state of an edited or deleted message to
+// the room. The change is already saved, so a failure is only logged.
+func (uc *ChatApplication) publishMessageChange(ctx context.Context, eventType string, message chat.Message, changedAt time.Time) {
+	recipients, err := roomRecipients(ctx, uc.chatRepository, message.RoomID.String())
+	if err != nil {
+		return
+	}
+
+	if err := uc.eventPublisher.Publish(ctx, chat.Event{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		RoomID:     message.RoomID,
+		Data:       message,
+		CreatedAt:  changedAt,
+		Recipients: recipients,
+	}); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
+
+// publishReadReceipt lets the room, the reader's other devices included, know
+// how far the user has read. Failures are only logged like for messages.
+func (uc *ChatApplication) publishReadReceipt(ctx context.Context, cursor chat.ReadCursor) {
+	recipients, err := roomRecipients(ctx, uc.chatRepository, cursor.RoomID.String())
+	if err != nil {
+		return
+	}
+
+	if err := uc.eventPublisher.Publish(ctx, chat.Event{
+		ID:         uuid.NewString(),
+		Type:       chat.EventMessageRead,
+		RoomID:     cursor.RoomID,
+		Data:       cursor,
+		CreatedAt:  cursor.ReadAt,
+		Recipients: recipients,
+	}); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
+
+// publishMessageStatuses tells the senders of the messages their status now,
+// in one event per sender and room. A receipt does not always change the
+// aggregated status, so senders can get the same status twice.
+func (uc *ChatApplication) publishMessageStatuses(ctx context.Context, messages []chat.Message) {
+	type senderRoom struct {
+		userID uuid.UUID
+		roomID uuid.UUID
+	}
+
+	var order []senderRoom
+	batches := map[senderRoom]*chat.MessageStatusBatch{}
+
+	for _, message := range messages {
+		if message.Status == chat.MessageStatusSent {
+			continue
+		}
+
+		key := senderRoom{message.UserID, message.RoomID}
+		batch, ok := batches[key]
+		if !ok {
+			batch = &chat.MessageStatusBatch{RoomID: message.RoomID}
+			batches[key] = batch
+			order = append(order, key)
+		}
+
+		batch.Messages = append(batch.Messages, chat.MessageStatusUpdate{
+			MessageID: message.ID,
+			RoomID:    message.RoomID,
+			Status:    message.Status,
+		})
+	}
+
+	now := time.Now()
+
+	for _, key := range order {
+		if err := uc.eventPublisher.Publish(ctx, chat.Event{
+			ID:         uuid.NewString(),
+			Type:       chat.EventMessageStatus,
+			RoomID:     key.roomID,
+			Data:       *batches[key],
+			CreatedAt:  now,
+			Recipients: []uuid.UUID{key.userID},
+		}); err != nil {
+			common.Log(common.LOG_LEVEL_ERROR, err.Error())
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// inviteTokenAudience keeps invite tokens and login tokens, signed with the
+// same secret, from being mistaken for one another.
+const inviteTokenAudience = "room_invite"
+
+var ErrInvalidInvite = errors.New("invite link is invalid")
+var ErrInviteExpired = errors.New("invite link has expired")
+var ErrInviteRevoked = errors.New("invite link has been revoked")
+var ErrInviteUsedUp = errors.New("invite link has reached its maximum uses")
+var ErrInviteNotFound = errors.New("invite not found")
+var ErrAlreadyRoomMember = errors.New("you are already a member of this room")
+
+type InviteApplication struct {
+	chatRepository   chat.IChatRepository
+	inviteRepository chat.IInviteRepository
+	eventPublisher   chat.IEventPublisher
+	roomPolicy       *RoomPolicy
+}
+
+func NewInviteApplication(chatRepository chat.IChatRepository, inviteRepository chat.IInviteRepository, eventPublisher chat.IEventPublisher) chat.IInviteUseCase {
+	return &InviteApplication{chatRepository, inviteRepository, eventPublisher, NewRoomPolicy(chatRepository)}
+}
+
+func (uc *InviteApplication) CreateInvite(ctx context.Context, params chat.CreateInviteParam) (invite chat.RoomInvite, err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageInvites); err != nil {
+		return
+	}
+
+	roomID, err := uuid.Parse(params.RoomID)
+	if err != nil {
+		return
+	}
+
+	userID, err := uuid.Parse(params.CurrentUserID)
+	if err != nil {
+		return
+	}
+
+	invite, err = uc.inviteRepository.InsertInvite(ctx, chat.RoomInvite{
+		RoomID:    roomID,
+		CreatedBy: userID,
+		MaxUses:   params.MaxUses,
+		ExpiresAt: time.Now().Add(params.ExpiresIn),
+	})
+	if err != nil {
+		return
+	}
+
+	invite.Token, err = signInviteToken(invite)
+	return
+}
+
+// GetRoomInvites lists the invites that can still be redeemed.
+func (uc *InviteApplication) GetRoomInvites(ctx context.Context, params chat.RoomMembersParam) (invites []chat.RoomInvite, err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageInvites); err != nil {
+		return
+	}
+
+	invites, err = uc.inviteRepository.GetActiveInvitesByRoomID(ctx, params.RoomID)
+	if err != nil {
+		return
+	}
+
+	for i := range invites {
+		if invites[i].Token, err = signInviteToken(invites[i]); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (uc *InviteApplication) RevokeInvite(ctx context.Context, params chat.RoomInviteParam) (err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageInvites); err != nil {
+		return
+	}
+
+	err = uc.inviteRepository.RevokeInvite(ctx, params.RoomID, params.InviteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrInviteNotFound
+	}
+
+	return
+}
+
+// GetInviteRedemptions tells who joined the room through the invite, revoked
+// and expired ones included.
+func (uc *InviteApplication) GetInviteRedemptions(ctx context.Context, params chat.RoomInviteParam) (redemptions []chat.InviteRedemption, err error) {
+	if _, err = uc.roomPolicy.Authorize(ctx, params.RoomID, params.CurrentUserEmail, RoomActionManageInvites); err != nil {
+		return
+	}
+
+	invite, err := uc.inviteRepository.GetInviteByID(ctx, params.InviteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrInviteNotFound
+		}
+		return
+	}
+
+	if invite.RoomID.String() != params.RoomID {
+		err = ErrInviteNotFound
+		return
+	}
+
+	redemptions, err = uc.inviteRepository.GetInviteRedemptions(ctx, params.InviteID)
+	return
+}
+
+func (uc *InviteApplication) AcceptInvite(ctx context.Context, params chat.AcceptInviteParam) (member chat.RoomMember, err error) {
+	inviteID, err := parseInviteToken(params.Token)
+	if err != nil {
+		return
+	}
+
+	invite, err := uc.inviteRepository.GetInviteByID(ctx, inviteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrInvalidInvite
+		}
+		return
+	}
+
+	if err = inviteUsable(invite); err != nil {
+		return
+	}
+
+	room, err := uc.chatRepository.GetRoomByID(ctx, invite.RoomID.String())
+	if err != nil {
+		return
+	}
+
+	if room.ArchivedAt != nil {
+		err = ErrRoomArchived
+		return
+	}
+
+	isBanned, err := uc.chatRepository.IsBannedFromRoom(ctx, invite.RoomID.String(), params.CurrentUserID)
+	if err != nil {
+		return
+	}
+
+	if isBanned {
+		err = ErrBannedFromRoom
+		return
+	}
+
+	// membership is checked by the redemption itself, so a double accept
+	// cannot slip in between
+	member, joined, err := uc.inviteRepository.RedeemInvite(ctx, inviteID, params.CurrentUserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = uc.unusableInvite(ctx, inviteID)
+		}
+		return
+	}
+
+	if !joined {
+		err = ErrAlreadyRoomMember
+		return
+	}
+
+	publishMemberJoined(ctx, uc.chatRepository, uc.eventPublisher, member)
+	return
+}
+
+// unusableInvite tells why an invite that passed the checks could not be
+// redeemed after all, it was revoked, expired or used up meanwhile.
+func (uc *InviteApplication) unusableInvite(ctx context.Context, inviteID string) (err error) {
+	invite, err := uc.inviteRepository.GetInviteByID(ctx, inviteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrInvalidInvite
+		}
+		return
+	}
+
+	if err = inviteUsable(invite); err == nil {
+		err = ErrInviteUsedUp
+	}
+	return
+}
+
+func inviteUsable(invite chat.RoomInvite) (err error) {
+	switch {
+	case invite.RevokedAt != nil:
+		err = ErrInviteRevoked
+	case !invite.ExpiresAt.After(time.Now()):
+		err = ErrInviteExpired
+	case invite.Uses >= invite.MaxUses:
+		err = ErrInviteUsedUp
+	}
+	return
+}
+
+// publishMemberJoined tells the room, the new member included, who joined.
+// The member is already in the room at this point, so a failure is only logged.
+func publishMemberJoined(ctx context.Context, chatRepository chat.IChatRepository, eventPublisher chat.IEventPublisher, member chat.RoomMember) {
+	recipients, err := roomRecipients(ctx, chatRepository, member.RoomID.String())
+	if err != nil {
+		return
+	}
+
+	if err := eventPublisher.Publish(ctx, chat.Event{
+		ID:         uuid.NewString(),
+		Type:       chat.EventMemberJoined,
+		RoomID:     member.RoomID,
+		Data:       member,
+		CreatedAt:  member.JoinedAt,
+		Recipients: recipients,
+	}); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
+
+// signInviteToken only carries the invite id, the invite row stays the
+// source of truth for uses and revocation.
+func signInviteToken(invite chat.RoomInvite) (token string, err error) {
+	tokenJwt := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"invite_id": invite.ID.String(),
+		"aud":       inviteTokenAudience,
+		"exp":       invite.ExpiresAt.Unix(),
+	})
+
+	token, err = tokenJwt.SignedString([]byte(os.Getenv("SECRET")))
+	return
+}
+
+// parseInviteToken returns ErrInviteExpired for expired tokens and
+// ErrInvalidInvite for anything else it cannot trust.
+func parseInviteToken(token string) (inviteID string, err error) {
+	claims := jwt.MapClaims{}
+	_, errToken := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(inviteTokenAudience), jwt.WithExpirationRequired())
+	if errToken != nil {
+		err = ErrInvalidInvite
+		if errors.Is(errToken, jwt.ErrTokenExpired) {
+			err = ErrInviteExpired
+		}
+		return
+	}
+
+	inviteID, _ = claims["invite_id"].(string)
+	if _, errID := uuid.Parse(inviteID); errID != nil {
+		err = ErrInvalidInvite
+	}
+
+	return
+}
@@ -0,0 +1,233 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+	"github.com/google/uuid"
+)
+
+// PresenceConnectionTTL is how long a connection counts as open without a
+// heartbeat, so connections of a crashed instance do not keep users online.
+const PresenceConnectionTTL = 2 * time.Minute
+
+var ErrInvalidPresenceStatus = errors.New("status must be online, away or offline")
+
+type PresenceApplication struct {
+	presenceStore  chat.IPresenceStore
+	userRepository chat.IUserRepository
+	chatRepository chat.IChatRepository
+	eventPublisher chat.IEventPublisher
+}
+
+func NewPresenceApplication(presenceStore chat.IPresenceStore, userRepository chat.IUserRepository, chatRepository chat.IChatRepository, eventPublisher chat.IEventPublisher) chat.IPresenceUseCase {
+	return &PresenceApplication{presenceStore, userRepository, chatRepository, eventPublisher}
+}
+
+// Connect registers a realtime connection, the user comes online with the first one.
+func (uc *PresenceApplication) Connect(ctx context.Context, userID string) (connectionID string, err error) {
+	connectionID = uuid.NewString()
+
+	connections, err := uc.presenceStore.AddConnection(ctx, userID, connectionID, PresenceConnectionTTL)
+	if err != nil {
+		return
+	}
+
+	if connections == 1 {
+		uc.presenceChanged(ctx, userID)
+	}
+
+	return
+}
+
+// Heartbeat keeps the connection open and the user last seen now.
+func (uc *PresenceApplication) Heartbeat(ctx context.Context, userID string, connectionID string) (err error) {
+	if _, err = uc.presenceStore.AddConnection(ctx, userID, connectionID, PresenceConnectionTTL); err != nil {
+		return
+	}
+
+	err = uc.userRepository.UpdateLastSeen(ctx, userID, time.Now())
+	return
+}
+
+// Disconnect drops a realtime connection, the user goes offline with the last one.
+func (uc *PresenceApplication) Disconnect(ctx context.Context, userID string, connectionID string) (err error) {
+	connections, err := uc.presenceStore.RemoveConnection(ctx, userID, connectionID)
+	if err != nil {
+		return
+	}
+
+	if connections == 0 {
+		uc.presenceChanged(ctx, userID)
+	}
+
+	return
+}
+
+func (uc *PresenceApplication) SetStatus(ctx context.Context, userID string, status string) (err error) {
+	switch status {
+	case chat.PresenceOnline, chat.PresenceAway, chat.PresenceOffline:
+	default:
+		err = ErrInvalidPresenceStatus
+		return
+	}
+
+	if err = uc.presenceStore.SetStatus(ctx, userID, status); err != nil {
+		return
+	}
+
+	uc.presenceChanged(ctx, userID)
+	return
+}
+
+// GetPresence answers as if the user did not exist when they share no room
+// with the current user, so ids cannot be probed.
+func (uc *PresenceApplication) GetPresence(ctx context.Context, currentUserEmail string, userID string) (presence chat.Presence, err error) {
+	user, err := uc.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = common.UserNotFoundError
+		}
+		return
+	}
+
+	if user.Email != currentUserEmail {
+		roomMates, errRoomMates := uc.chatRepository.GetRoomMatesByEmail(ctx, currentUserEmail)
+		if errRoomMates != nil {
+			err = errRoomMates
+			return
+		}
+
+		if !slices.ContainsFunc(roomMates, func(roomMate chat.User) bool { return roomMate.ID == user.ID }) {
+			err = common.UserNotFoundError
+			return
+		}
+	}
+
+	presence, err = uc.presenceOf(ctx, user)
+	return
+}
+
+// GetRoomMatesPresence returns the presence of everyone sharing a room with the user.
+func (uc *PresenceApplication) GetRoomMatesPresence(ctx context.Context, currentUserEmail string) (presences []chat.Presence, err error) {
+	users, err := uc.chatRepository.GetRoomMatesByEmail(ctx, currentUserEmail)
+	if err != nil {
+		return
+	}
+
+	presences = make([]chat.Presence, 0, len(users))
+	for _, user := range users {
+		presence, errPresence := uc.presenceOf(ctx, user)
+		if errPresence != nil {
+			err = errPresence
+			return
+		}
+		presences = append(presences, presence)
+	}
+
+	return
+}
+
+func (uc *PresenceApplication) ExpireConnections(ctx context.Context) (err error) {
+	userIDs, err := uc.presenceStore.PopExpiredUsers(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, userID := range userIDs {
+		uc.presenceChanged(ctx, userID)
+	}
+
+	return
+}
+
+// RunConnectionExpiry expires connections every interval until the context
+// is done.
+func RunConnectionExpiry(ctx context.Context, presence chat.IPresenceUseCase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := presence.ExpireConnections(ctx); err != nil {
+				common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			}
+		}
+	}
+}
+
+// presenceOf is offline without any open connection, otherwise the status
+// the user picked, online by default.
+func (uc *PresenceApplication) presenceOf(ctx context.Context, user chat.User) (presence chat.Presence, err error) {
+	presence = chat.Presence{
+		UserID:     user.ID,
+		Status:     chat.PresenceOffline,
+		LastSeenAt: user.LastSeenAt,
+	}
+
+	connections, err := uc.presenceStore.CountConnections(ctx, user.ID.String())
+	if err != nil || connections == 0 {
+		return
+	}
+
+	status, err := uc.presenceStore.GetStatus(ctx, user.ID.String())
+	if err != nil {
+		return
+	}
+
+	presence.Status = chat.PresenceOnline
+	if status != "" {
+		presence.Status = status
+	}
+
+	return
+}
+
+// presenceChanged records the user as last seen now and tells everyone
+// sharing a room with them. Failures are only logged, the change itself
+// already happened.
+func (uc *PresenceApplication) presenceChanged(ctx context.Context, userID string) {
+	if err := uc.userRepository.UpdateLastSeen(ctx, userID, time.Now()); err != nil {
+		return
+	}
+
+	user, err := uc.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	presence, err := uc.presenceOf(ctx, user)
+	if err != nil {
+		return
+	}
+
+	roomMates, err := uc.chatRepository.GetRoomMatesByEmail(ctx, user.Email)
+	if err != nil {
+		return
+	}
+
+	recipients := []uuid.UUID{user.ID}
+	for _, roomMate := range roomMates {
+		if roomMate.ID != user.ID {
+			recipients = append(recipients, roomMate.ID)
+		}
+	}
+
+	if err := uc.eventPublisher.Publish(ctx, chat.Event{
+		ID:         uuid.NewString(),
+		Type:       chat.EventPresenceChanged,
+		Data:       presence,
+		CreatedAt:  time.Now(),
+		Recipients: recipients,
+	}); err != nil {
+		common.Log(common.LOG_LEVEL_ERROR, err.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+	"github.com/fikrihkll/chat-app/common"
+)
+
+// RetentionSweeper purges the messages that outlived the retention of their
+// room. Each batch is its own short transaction so the messages table is
+// never locked for long, and several instances can sweep at the same time.
+type RetentionSweeper struct {
+	chatRepository chat.IChatRepository
+	batchSize      int
+	dryRun         bool
+}
+
+func NewRetentionSweeper(chatRepository chat.IChatRepository, batchSize int, dryRun bool) *RetentionSweeper {
+	return &RetentionSweeper{chatRepository, batchSize, dryRun}
+}
+
+// Run sweeps every interval until the context is done.
+func (sweeper *RetentionSweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := sweeper.Sweep(ctx, time.Now()); err != nil {
+				common.Log(common.LOG_LEVEL_ERROR, err.Error())
+			}
+		}
+	}
+}
+
+// Sweep purges, or only counts in dry-run mode, the expired messages of every
+// room with a retention. Rooms with nothing expired are left out of reports.
+func (sweeper *RetentionSweeper) Sweep(ctx context.Context, now time.Time) (reports []chat.RetentionReport, err error) {
+	rooms, err := sweeper.chatRepository.GetRoomsWithRetention(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, room := range rooms {
+		report := chat.RetentionReport{
+			RoomID: room.ID,
+			Before: now.AddDate(0, 0, -retentionDays(room)),
+			DryRun: sweeper.dryRun,
+		}
+
+		if sweeper.dryRun {
+			report.Messages, err = sweeper.chatRepository.CountMessagesBefore(ctx, room.ID.String(), report.Before)
+		} else {
+			report.Messages, err = sweeper.purge(ctx, room.ID.String(), report.Before)
+		}
+		if err != nil {
+			return
+		}
+
+		if report.Messages == 0 {
+			continue
+		}
+
+		reports = append(reports, report)
+		sweeper.log(report)
+	}
+
+	return
+}
+
+func (sweeper *RetentionSweeper) purge(ctx context.Context, roomID string, before time.Time) (purged int64, err error) {
+	for {
+		deleted, errDelete := sweeper.chatRepository.DeleteMessagesBefore(ctx, roomID, before, sweeper.batchSize)
+		purged += deleted
+		if errDelete != nil {
+			err = errDelete
+			return
+		}
+
+		if deleted == 0 || deleted < int64(sweeper.batchSize) || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (sweeper *RetentionSweeper) log(report chat.RetentionReport) {
+	if report.DryRun {
+		common.Log(common.LOG_LEVEL_INFO, fmt.Sprintf("retention dry run: room %s would lose %d messages created before %s", report.RoomID, report.Messages, report.Before.Format(time.RFC3339)))
+		return
+	}
+
+	common.Log(common.LOG_LEVEL_INFO, fmt.Sprintf("retention: purged %d messages created before %s from room %s", report.Messages, report.Before.Format(time.RFC3339), report.RoomID))
+}
+
+func retentionDays(room chat.Room) int {
+	if room.RetentionDays == nil {
+		return 0
+	}
+
+	return *room.RetentionDays
+}
@@ -0,0 +1,109 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/fikrihkll/chat-app/application/chat"
+)
+
+var ErrRoomPermissionDenied = errors.New("your role in this room does not allow this")
+var ErrRoomArchived = errors.New("this room is archived and read-only")
+
+// RoomAction is something a member does in a room, each one needs a minimum role.
+type RoomAction string
+
+const (
+	RoomActionRead             RoomAction = "read"
+	RoomActionWrite            RoomAction = "write"
+	RoomActionManageRoles      RoomAction = "manage_roles"
+	RoomActionManageInvites    RoomAction = "manage_invites"
+	RoomActionManageRoom       RoomAction = "manage_room"
+	RoomActionManageMembers    RoomAction = "manage_members"
+	RoomActionViewRevisions    RoomAction = "view_revisions"
+	RoomActionModerateMessages RoomAction = "moderate_messages"
+	RoomActionPurgeMessages    RoomAction = "purge_messages"
+)
+
+var roomRoleRanks = map[string]int{
+	chat.RoomRoleMember: 1,
+	chat.RoomRoleAdmin:  2,
+	chat.RoomRoleOwner:  3,
+}
+
+var roomActionRoles = map[RoomAction]string{
+	RoomActionRead:             chat.RoomRoleMember,
+	RoomActionWrite:            chat.RoomRoleMember,
+	RoomActionManageRoles:      chat.RoomRoleOwner,
+	RoomActionManageInvites:    chat.RoomRoleAdmin,
+	RoomActionManageRoom:       chat.RoomRoleAdmin,
+	RoomActionManageMembers:    chat.RoomRoleAdmin,
+	RoomActionViewRevisions:    chat.RoomRoleAdmin,
+	RoomActionModerateMessages: chat.RoomRoleAdmin,
+	RoomActionPurgeMessages:    chat.RoomRoleAdmin,
+}
+
+// archivedRoomActions are refused once a room is archived, managing the room
+// stays possible so it can be unarchived.
+var archivedRoomActions = map[RoomAction]bool{
+	RoomActionWrite:         true,
+	RoomActionManageInvites: true,
+}
+
+// RoomPolicy decides what a user may do in a room based on their role there.
+// Use cases ask it before touching a room instead of checking roles themselves.
+type RoomPolicy struct {
+	chatRepository chat.IChatRepository
+}
+
+func NewRoomPolicy(chatRepository chat.IChatRepository) *RoomPolicy {
+	return &RoomPolicy{chatRepository}
+}
+
+// Authorize returns the user's membership when their role allows the action,
+// ErrNotRoomMember when they are not in the room, ErrRoomPermissionDenied
+// when their role is too low and ErrRoomArchived when the room is read-only.
+func (policy *RoomPolicy) Authorize(ctx context.Context, roomID string, userEmail string, action RoomAction) (member chat.RoomMember, err error) {
+	member, err = policy.chatRepository.GetRoomMember(ctx, roomID, userEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNotRoomMember
+		}
+		return
+	}
+
+	if !RoleAllows(member.Role, action) {
+		err = ErrRoomPermissionDenied
+		return
+	}
+
+	if archivedRoomActions[action] {
+		room, errRoom := policy.chatRepository.GetRoomByID(ctx, roomID)
+		if errRoom != nil {
+			err = errRoom
+			return
+		}
+
+		if room.ArchivedAt != nil {
+			err = ErrRoomArchived
+		}
+	}
+
+	return
+}
+
+func RoleAllows(role string, action RoomAction) bool {
+	required, ok := roomActionRoles[action]
+	if !ok {
+		return false
+	}
+
+	return roomRoleRanks[role] >= roomRoleRanks[required]
+}
+
+// Outranks tells whether role is strictly above other, admins cannot remove
+// or ban other admins nor the owners.
+func Outranks(role string, other string) bool {
+	return roomRoleRanks[role] > roomRoleRanks[other]
+}
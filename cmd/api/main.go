@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,7 +11,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/fikrihkll/chat-app/application/chat"
 	chatDeliveryHttp "github.com/fikrihkll/chat-app/application/chat/delivery/http"
+	"github.com/fikrihkll/chat-app/application/chat/realtime"
 	repositories "github.com/fikrihkll/chat-app/application/chat/repositories"
 	usecases "github.com/fikrihkll/chat-app/application/chat/usecases"
 
@@ -44,31 +47,107 @@ func count() {
 	fmt.Println("count finish")
 }
 
-func initInfrastructure(cfg config.ApplicationConfig) (pgConn *sql.DB) {
+func initInfrastructure(cfg config.ApplicationConfig) (pgConn *sql.DB, sharedState infrastructure.SharedState) {
 
 	pgConn, err := infrastructure.NewPgConnection(cfg)
 	common.LogExit(err, common.LOG_LEVEL_ERROR)
+
+	// without redis the state is only shared within this instance
+	if cfg.RedisHost == "" {
+		if cfg.EventBus == realtime.EventBusRedis {
+			common.LogExit(errors.New("EVENT_BUS=redis needs REDIS_HOST"), common.LOG_LEVEL_ERROR)
+		}
+		return pgConn, infrastructure.NewMemorySharedState()
+	}
+
+	redisConn, err := infrastructure.NewRedisConnection(cfg)
+	common.LogExit(err, common.LOG_LEVEL_ERROR)
 	
-	return pgConn
+	return pgConn, infrastructure.NewRedisSharedState(redisConn)
+}
+
+// initEventPublisher builds the chain realtime events go through. Events
+// reaching this instance go to its own connections and to the embedded MQTT
+// broker, the event bus carries them to the other instances, and an external
+// MQTT broker only hears from the instance that saved the message.
+func initEventPublisher(cfg config.ApplicationConfig, pgConn *sql.DB, sharedState infrastructure.SharedState, hub *realtime.Hub, chatRepository chat.IChatRepository) chat.IEventPublisher {
+	var local chat.IEventPublisher = hub
+	var external chat.IEventPublisher
+
+	switch cfg.MqttMode {
+	case infrastructure.MqttModeEmbedded:
+		broker, err := infrastructure.NewMqttBroker(cfg, realtime.NewMqttAuthHook(chatRepository.IsRoomMember))
+		common.LogExit(err, common.LOG_LEVEL_ERROR)
+
+		local = realtime.NewFanoutEventPublisher(hub, realtime.NewMqttBrokerEventPublisher(broker))
+	case infrastructure.MqttModeExternal:
+		client, err := infrastructure.NewMqttClient(cfg, realtime.MqttServiceCredentials(cfg.MqttClientID))
+		common.LogExit(err, common.LOG_LEVEL_ERROR)
+
+		external = realtime.NewMqttClientEventPublisher(client)
+	}
+
+	publisher := local
+
+	switch cfg.EventBus {
+	case realtime.EventBusPostgres:
+		listener := infrastructure.NewPgListener(cfg, func(err error) {
+			common.Log(common.LOG_LEVEL_WARN, fmt.Sprintf("postgres listener: %s", err.Error()))
+		})
+		bus := realtime.NewPgEventBus(pgConn, listener, local, chatRepository)
+		go func() {
+			common.LogExit(bus.Listen(context.Background()), common.LOG_LEVEL_ERROR)
+		}()
+
+		publisher = bus
+	case realtime.EventBusRedis:
+		bus := realtime.NewPubSubEventBus(sharedState, local)
+		go func() {
+			common.LogExit(bus.Listen(context.Background()), common.LOG_LEVEL_ERROR)
+		}()
+
+		publisher = bus
+	}
+
+	if external != nil {
+		publisher = realtime.NewFanoutEventPublisher(publisher, external)
+	}
+
+	return publisher
 }
 
 func initApplication(httpServer *echo.Echo, cfg config.ApplicationConfig) {
-	pgConn := initInfrastructure(cfg)
+	pgConn, sharedState := initInfrastructure(cfg)
 
 	// Repositories
 	chatPersistRepo := repositories.NewChatRepositoryPostgree(pgConn)
 	userPersistRepo := repositories.NewUserRepositoryPostgree(pgConn)
+	invitePersistRepo := repositories.NewInviteRepositoryPostgree(pgConn)
+	rateLimiter := repositories.NewRateLimiterKeyValue(sharedState)
+	presenceStore := repositories.NewPresenceStoreKeyValue(sharedState)
+
+	// realtime
+	hub := realtime.NewHub(realtime.DefaultSubscriptionBuffer)
+	eventPublisher := initEventPublisher(cfg, pgConn, sharedState, hub, chatPersistRepo)
 
 	// usecases
-	chatUsecases := usecases.NewChatApplication(chatPersistRepo, userPersistRepo)
+	chatUsecases := usecases.NewChatApplication(chatPersistRepo, userPersistRepo, eventPublisher, rateLimiter, cfg.MessageEditWindow)
 	authUsecases := usecases.NewUserApplication(userPersistRepo)
+	presenceUsecases := usecases.NewPresenceApplication(presenceStore, userPersistRepo, chatPersistRepo, eventPublisher)
+	inviteUsecases := usecases.NewInviteApplication(chatPersistRepo, invitePersistRepo, eventPublisher)
+
+	// background jobs
+	retentionSweeper := usecases.NewRetentionSweeper(chatPersistRepo, cfg.RetentionBatchSize, cfg.RetentionDryRun)
+	go retentionSweeper.Run(context.Background(), cfg.RetentionSweepInterval)
+	go usecases.RunConnectionExpiry(context.Background(), presenceUsecases, usecases.PresenceConnectionTTL/4)
 
 	
-	httpApi := chatDeliveryHttp.NewChatHttpApi(chatUsecases, authUsecases)
+	httpApi := chatDeliveryHttp.NewChatHttpApi(chatUsecases, authUsecases, presenceUsecases, inviteUsecases, hub)
 	
 	// handle http request response
 	httpApi.HandleAuthRoute(httpServer)
 	httpApi.HandleChatRoute(httpServer)
+	httpApi.HandleUserRoute(httpServer)
 	httpApi.HandleRootRoute(httpServer)
 }
 
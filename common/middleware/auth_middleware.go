@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/fikrihkll/chat-app/common"
@@ -17,31 +18,76 @@ func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 			return echo.NewHTTPError(http.StatusUnauthorized, "Missing Authorization header")
 		}
 
-		tokenString := authHeader[len("Bearer "):]
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+		}
 
-		claims := &jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unexpected signing method")
-			}
-			return []byte(os.Getenv("SECRET")), nil
-		})
+		tokenString := authHeader[len("Bearer "):]
 
-		if err != nil || !token.Valid {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+		claims, err := ParseToken(tokenString)
+		if err != nil {
+			return err
 		}
 
-		if float64(time.Now().Unix()) > (*claims)["exp"].(float64) {
-			return echo.NewHTTPError(http.StatusUnauthorized, common.UnauthorizedError.Error())
+		c.Set("id", claims.UserID)
+		c.Set("email", claims.Email)
+
+		return next(c)
+	}
+}
+
+type TokenClaims struct {
+	UserID string
+	Email  string
+}
+
+// ParseToken validates a token issued by the auth use case and returns its claims.
+// The returned error is an *echo.HTTPError so it can be sent as is.
+func ParseToken(tokenString string) (tokenClaims TokenClaims, err error) {
+	claims := &jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Unexpected signing method")
 		}
-		
-		userID, ok := (*claims)["user_id"].(string)
-		if !ok {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid User ID in Token")
+		return []byte(os.Getenv("SECRET")), nil
+	})
+
+	if err != nil || !token.Valid {
+		err = echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	exp, ok := (*claims)["exp"].(float64)
+	if !ok || float64(time.Now().Unix()) > exp {
+		err = echo.NewHTTPError(http.StatusUnauthorized, common.UnauthorizedError.Error())
+		return
+	}
+
+	userID, ok := (*claims)["user_id"].(string)
+	if !ok {
+		err = echo.NewHTTPError(http.StatusUnauthorized, "Invalid User ID in Token")
+		return
+	}
+
+	email, _ := (*claims)["email"].(string)
+
+	tokenClaims = TokenClaims{
+		UserID: userID,
+		Email:  email,
+	}
+
+	return
+}
+
+// QueryTokenMiddleware lets clients that cannot set headers, such as the
+// browser EventSource, pass their token in the access_token query param.
+// It has to run before AuthMiddleware.
+func QueryTokenMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := c.QueryParam("access_token")
+		if token != "" && c.Request().Header.Get("Authorization") == "" {
+			c.Request().Header.Set("Authorization", "Bearer "+token)
 		}
-		
-		c.Set("id", userID)
-		c.Set("email", (*claims)["email"])
 
 		return next(c)
 	}
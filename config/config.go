@@ -4,18 +4,32 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type ApplicationConfig struct {
-	PostgreeHost string
-	PostgreeUser string
-	PostgreePass string
-	PostgreeDb   string
-	PostgreePort int
-	PostgreeSsl  string
-	HTTPApiPort  string
+	PostgreeHost  string
+	PostgreeUser  string
+	PostgreePass  string
+	PostgreeDb    string
+	PostgreePort  int
+	PostgreeSsl   string
+	HTTPApiPort   string
+	MqttMode      string
+	MqttAddress   string
+	MqttBrokerURL string
+	MqttClientID  string
+	EventBus      string
+	RedisHost     string
+	RedisPassword string
+
+	MessageEditWindow time.Duration
+
+	RetentionSweepInterval time.Duration
+	RetentionBatchSize     int
+	RetentionDryRun        bool
 }
 
 func Load(configFile ...string) ApplicationConfig {
@@ -30,14 +44,64 @@ func Load(configFile ...string) ApplicationConfig {
 		log.Fatal(err)
 	}
 
+	messageEditWindow := 15 * time.Minute
+	if value := os.Getenv("MESSAGE_EDIT_WINDOW"); value != "" {
+		if messageEditWindow, err = time.ParseDuration(value); err != nil {
+			log.Fatal(err)
+		}
+		if messageEditWindow <= 0 {
+			log.Fatal("MESSAGE_EDIT_WINDOW must be greater than zero")
+		}
+	}
+
+	retentionSweepInterval := time.Hour
+	if value := os.Getenv("RETENTION_SWEEP_INTERVAL"); value != "" {
+		if retentionSweepInterval, err = time.ParseDuration(value); err != nil {
+			log.Fatal(err)
+		}
+		if retentionSweepInterval <= 0 {
+			log.Fatal("RETENTION_SWEEP_INTERVAL must be greater than zero")
+		}
+	}
+
+	retentionBatchSize := 1000
+	if value := os.Getenv("RETENTION_BATCH_SIZE"); value != "" {
+		if retentionBatchSize, err = strconv.Atoi(value); err != nil {
+			log.Fatal(err)
+		}
+		if retentionBatchSize <= 0 {
+			log.Fatal("RETENTION_BATCH_SIZE must be greater than zero")
+		}
+	}
+
+	retentionDryRun := false
+	if value := os.Getenv("RETENTION_DRY_RUN"); value != "" {
+		if retentionDryRun, err = strconv.ParseBool(value); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	return ApplicationConfig{
-		PostgreeHost: os.Getenv("PG_DATABASE_HOST"),
-		PostgreeUser: os.Getenv("PG_DATABASE_USERNAME"),
-		PostgreePass: os.Getenv("PG_DATABASE_PASSWORD"),
-		PostgreeDb:   os.Getenv("PG_DATABASE_NAME"),
-		PostgreePort: postgreeDbPort,
-		PostgreeSsl:  os.Getenv("PG_DATABASE_SSL_MODE"),
-		HTTPApiPort:  os.Getenv("HTTP_API_PORT"),
+		PostgreeHost:  os.Getenv("PG_DATABASE_HOST"),
+		PostgreeUser:  os.Getenv("PG_DATABASE_USERNAME"),
+		PostgreePass:  os.Getenv("PG_DATABASE_PASSWORD"),
+		PostgreeDb:    os.Getenv("PG_DATABASE_NAME"),
+		PostgreePort:  postgreeDbPort,
+		PostgreeSsl:   os.Getenv("PG_DATABASE_SSL_MODE"),
+		HTTPApiPort:   os.Getenv("HTTP_API_PORT"),
+		MqttMode:      os.Getenv("MQTT_MODE"),
+		MqttAddress:   os.Getenv("MQTT_ADDRESS"),
+		MqttBrokerURL: os.Getenv("MQTT_BROKER_URL"),
+		MqttClientID:  os.Getenv("MQTT_CLIENT_ID"),
+		EventBus:      os.Getenv("EVENT_BUS"),
+		RedisHost:     os.Getenv("REDIS_HOST"),
+		RedisPassword: os.Getenv("REDIS_PASSWORD"),
+
+		MessageEditWindow: messageEditWindow,
+
+		RetentionSweepInterval: retentionSweepInterval,
+		RetentionBatchSize:     retentionBatchSize,
+		RetentionDryRun:        retentionDryRun,
 	}
 
-}
\ No newline at end of file
+}
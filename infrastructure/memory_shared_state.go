@@ -0,0 +1,234 @@
+package infrastructure
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	memorySubscriptionBuffer = 256
+	// expired entries are swept every memorySweepEvery writes
+	memorySweepEvery = 1024
+)
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+func (entry memoryEntry) expired(now time.Time) bool {
+	return !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt)
+}
+
+// MemorySharedState is the in-process stand-in for RedisSharedState.
+type MemorySharedState struct {
+	mu          sync.Mutex
+	entries     map[string]memoryEntry
+	subscribers map[string]map[chan []byte]struct{}
+	sets        map[string]map[string]time.Time
+	schedules   map[string]map[string]time.Time
+	writes      int
+}
+
+func NewMemorySharedState() SharedState {
+	return &MemorySharedState{
+		entries:     map[string]memoryEntry{},
+		subscribers: map[string]map[chan []byte]struct{}{},
+		sets:        map[string]map[string]time.Time{},
+		schedules:   map[string]map[string]time.Time{},
+	}
+}
+
+func (state *MemorySharedState) Publish(ctx context.Context, channel string, payload []byte) (err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	for subscriber := range state.subscribers[channel] {
+		select {
+		case subscriber <- payload:
+		default:
+			// like Redis, a subscriber that does not keep up loses messages
+		}
+	}
+
+	return
+}
+
+func (state *MemorySharedState) Subscribe(ctx context.Context, channel string) (payloads <-chan []byte, err error) {
+	subscriber := make(chan []byte, memorySubscriptionBuffer)
+
+	state.mu.Lock()
+	if state.subscribers[channel] == nil {
+		state.subscribers[channel] = map[chan []byte]struct{}{}
+	}
+	state.subscribers[channel][subscriber] = struct{}{}
+	state.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		state.mu.Lock()
+		delete(state.subscribers[channel], subscriber)
+		close(subscriber)
+		state.mu.Unlock()
+	}()
+
+	payloads = subscriber
+	return
+}
+
+func (state *MemorySharedState) Get(ctx context.Context, key string) (value string, err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	entry, ok := state.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		err = ErrKeyNotFound
+		return
+	}
+
+	value = entry.value
+	return
+}
+
+func (state *MemorySharedState) Set(ctx context.Context, key string, value string, ttl time.Duration) (err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.entries[key] = memoryEntry{value: value, expiresAt: expiresAt(ttl)}
+	state.sweep()
+	return
+}
+
+func (state *MemorySharedState) Incr(ctx context.Context, key string, ttl time.Duration) (value int64, err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	entry, ok := state.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		entry = memoryEntry{value: "0", expiresAt: expiresAt(ttl)}
+	}
+
+	if value, err = strconv.ParseInt(entry.value, 10, 64); err != nil {
+		return
+	}
+	value++
+
+	entry.value = strconv.FormatInt(value, 10)
+	state.entries[key] = entry
+	state.sweep()
+	return
+}
+
+func (state *MemorySharedState) Del(ctx context.Context, key string) (err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	delete(state.entries, key)
+	return
+}
+
+func (state *MemorySharedState) AddToSet(ctx context.Context, key string, member string, expiresAt time.Time) (count int64, err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.sets[key] == nil {
+		state.sets[key] = map[string]time.Time{}
+	}
+	state.sets[key][member] = expiresAt
+
+	count = state.countSet(key)
+	return
+}
+
+func (state *MemorySharedState) RemoveFromSet(ctx context.Context, key string, member string) (count int64, err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	delete(state.sets[key], member)
+
+	count = state.countSet(key)
+	return
+}
+
+func (state *MemorySharedState) CountSet(ctx context.Context, key string) (count int64, err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	count = state.countSet(key)
+	return
+}
+
+func (state *MemorySharedState) Schedule(ctx context.Context, key string, member string, dueAt time.Time) (err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.schedules[key] == nil {
+		state.schedules[key] = map[string]time.Time{}
+	}
+	state.schedules[key][member] = dueAt
+	return
+}
+
+func (state *MemorySharedState) Unschedule(ctx context.Context, key string, member string) (err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	delete(state.schedules[key], member)
+	return
+}
+
+func (state *MemorySharedState) PopDue(ctx context.Context, key string, now time.Time) (members []string, err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	for member, dueAt := range state.schedules[key] {
+		if !now.Before(dueAt) {
+			members = append(members, member)
+			delete(state.schedules[key], member)
+		}
+	}
+
+	return
+}
+
+// countSet drops expired members first, it must be called with mu held
+func (state *MemorySharedState) countSet(key string) int64 {
+	now := time.Now()
+	for member, expiresAt := range state.sets[key] {
+		if !now.Before(expiresAt) {
+			delete(state.sets[key], member)
+		}
+	}
+
+	if len(state.sets[key]) == 0 {
+		delete(state.sets, key)
+	}
+
+	return int64(len(state.sets[key]))
+}
+
+// sweep must be called with mu held
+func (state *MemorySharedState) sweep() {
+	state.writes++
+	if state.writes%memorySweepEvery != 0 {
+		return
+	}
+
+	now := time.Now()
+	for key, entry := range state.entries {
+		if entry.expired(now) {
+			delete(state.entries, key)
+		}
+	}
+}
+
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(ttl)
+}
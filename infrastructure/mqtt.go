@@ -0,0 +1,64 @@
+package infrastructure
+
+import (
+	"errors"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/fikrihkll/chat-app/config"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+const (
+	MqttModeDisabled = "disabled"
+	MqttModeEmbedded = "embedded"
+	MqttModeExternal = "external"
+)
+
+var ErrMqttConnectTimeout = errors.New("timed out connecting to mqtt broker")
+
+// NewMqttBroker starts an in-process broker listening on MqttAddress.
+// The inline client is enabled so the API can publish without a network hop.
+func NewMqttBroker(config config.ApplicationConfig, authHook mqtt.Hook) (server *mqtt.Server, err error) {
+	server = mqtt.New(&mqtt.Options{
+		InlineClient: true,
+	})
+
+	if err = server.AddHook(authHook, nil); err != nil {
+		return
+	}
+
+	tcp := listeners.NewTCP(listeners.Config{
+		ID:      "tcp",
+		Address: config.MqttAddress,
+	})
+	if err = server.AddListener(tcp); err != nil {
+		return
+	}
+
+	err = server.Serve()
+	return
+}
+
+// NewMqttClient connects to an external broker. Credentials are asked again on
+// every reconnect so a fresh token can be handed out each time.
+func NewMqttClient(config config.ApplicationConfig, credentials paho.CredentialsProvider) (client paho.Client, err error) {
+	opts := paho.NewClientOptions().
+		AddBroker(config.MqttBrokerURL).
+		SetClientID(config.MqttClientID).
+		SetCredentialsProvider(credentials).
+		SetAutoReconnect(true).
+		SetConnectRetry(true)
+
+	client = paho.NewClient(opts)
+
+	token := client.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		err = ErrMqttConnectTimeout
+		return
+	}
+
+	err = token.Error()
+	return
+}
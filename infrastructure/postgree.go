@@ -1,14 +1,16 @@
 package infrastructure
 
 import (
-	"github.com/fikrihkll/chat-app/config"
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/fikrihkll/chat-app/config"
+	"github.com/lib/pq"
 )
 
-func NewPgConnection(config config.ApplicationConfig) (db *sql.DB, err error) {
-	dbURI := fmt.Sprintf(
+func pgConnectionString(config config.ApplicationConfig) string {
+	return fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=Asia/Jakarta",
 		config.PostgreeHost,
 		config.PostgreeUser,
@@ -17,7 +19,10 @@ func NewPgConnection(config config.ApplicationConfig) (db *sql.DB, err error) {
 		config.PostgreePort,
 		config.PostgreeSsl,
 	)
-	db, err = sql.Open("postgres", dbURI)
+}
+
+func NewPgConnection(config config.ApplicationConfig) (db *sql.DB, err error) {
+	db, err = sql.Open("postgres", pgConnectionString(config))
 	if err != nil {
 		return
 	}
@@ -31,4 +36,15 @@ func NewPgConnection(config config.ApplicationConfig) (db *sql.DB, err error) {
 	db.SetMaxOpenConns(5)
 
 	return
-}
\ No newline at end of file
+}
+
+// NewPgListener opens a dedicated connection for LISTEN, it reconnects on its own
+// and sends nil on its Notify channel once it is back. onError gets the
+// connection errors it recovers from.
+func NewPgListener(config config.ApplicationConfig, onError func(err error)) *pq.Listener {
+	return pq.NewListener(pgConnectionString(config), 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			onError(err)
+		}
+	})
+}
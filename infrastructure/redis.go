@@ -0,0 +1,171 @@
+package infrastructure
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/fikrihkll/chat-app/config"
+	"github.com/redis/go-redis/v9"
+)
+
+func NewRedisConnection(config config.ApplicationConfig) (client *redis.Client, err error) {
+	client = redis.NewClient(&redis.Options{
+		Addr:     config.RedisHost,
+		Password: config.RedisPassword,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = client.Ping(ctx).Err()
+	return
+}
+
+type RedisSharedState struct {
+	client *redis.Client
+}
+
+func NewRedisSharedState(client *redis.Client) SharedState {
+	return &RedisSharedState{client}
+}
+
+func (state *RedisSharedState) Publish(ctx context.Context, channel string, payload []byte) (err error) {
+	err = state.client.Publish(ctx, channel, payload).Err()
+	return
+}
+
+func (state *RedisSharedState) Subscribe(ctx context.Context, channel string) (payloads <-chan []byte, err error) {
+	pubsub := state.client.Subscribe(ctx, channel)
+
+	// wait for the confirmation so nothing published after we return is missed
+	if _, err = pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return
+	}
+
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(message.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	payloads = out
+	return
+}
+
+func (state *RedisSharedState) Get(ctx context.Context, key string) (value string, err error) {
+	value, err = state.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		err = ErrKeyNotFound
+	}
+	return
+}
+
+func (state *RedisSharedState) Set(ctx context.Context, key string, value string, ttl time.Duration) (err error) {
+	err = state.client.Set(ctx, key, value, ttl).Err()
+	return
+}
+
+func (state *RedisSharedState) Incr(ctx context.Context, key string, ttl time.Duration) (value int64, err error) {
+	pipe := state.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, ttl)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return
+	}
+
+	value = incr.Val()
+	return
+}
+
+func (state *RedisSharedState) Del(ctx context.Context, key string) (err error) {
+	err = state.client.Del(ctx, key).Err()
+	return
+}
+
+// Set members are kept in a sorted set scored by their expiry time.
+func (state *RedisSharedState) AddToSet(ctx context.Context, key string, member string, expiresAt time.Time) (count int64, err error) {
+	pipe := state.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(expiresAt.Unix()), Member: member})
+	pipe.ExpireAt(ctx, key, expiresAt)
+	card := state.countSet(ctx, pipe, key)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return
+	}
+
+	count = card.Val()
+	return
+}
+
+func (state *RedisSharedState) RemoveFromSet(ctx context.Context, key string, member string) (count int64, err error) {
+	pipe := state.client.TxPipeline()
+	pipe.ZRem(ctx, key, member)
+	card := state.countSet(ctx, pipe, key)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return
+	}
+
+	count = card.Val()
+	return
+}
+
+func (state *RedisSharedState) CountSet(ctx context.Context, key string) (count int64, err error) {
+	pipe := state.client.TxPipeline()
+	card := state.countSet(ctx, pipe, key)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return
+	}
+
+	count = card.Val()
+	return
+}
+
+// Schedules are sorted sets scored by the due time, without an expiry of
+// their own so nothing due is lost before PopDue sees it.
+func (state *RedisSharedState) Schedule(ctx context.Context, key string, member string, dueAt time.Time) (err error) {
+	err = state.client.ZAdd(ctx, key, redis.Z{Score: float64(dueAt.Unix()), Member: member}).Err()
+	return
+}
+
+func (state *RedisSharedState) Unschedule(ctx context.Context, key string, member string) (err error) {
+	err = state.client.ZRem(ctx, key, member).Err()
+	return
+}
+
+// popDueScript reads and removes in one step, so two instances popping at
+// the same time never get the same member.
+var popDueScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if #due > 0 then
+	redis.call('ZREM', KEYS[1], unpack(due))
+end
+return due
+`)
+
+func (state *RedisSharedState) PopDue(ctx context.Context, key string, now time.Time) (members []string, err error) {
+	members, err = popDueScript.Run(ctx, state.client, []string{key}, now.Unix()).StringSlice()
+	return
+}
+
+func (state *RedisSharedState) countSet(ctx context.Context, pipe redis.Pipeliner, key string) *redis.IntCmd {
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	return pipe.ZCard(ctx, key)
+}
@@ -0,0 +1,44 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+// PubSub carries payloads between the API instances.
+type PubSub interface {
+	Publish(ctx context.Context, channel string, payload []byte) (err error)
+	// Subscribe returns a channel that is closed once ctx is done.
+	Subscribe(ctx context.Context, channel string) (payloads <-chan []byte, err error)
+}
+
+// KeyValueStore keeps short lived state that every API instance can see.
+type KeyValueStore interface {
+	Get(ctx context.Context, key string) (value string, err error)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) (err error)
+	// Incr sets ttl only when the key is created by this call.
+	Incr(ctx context.Context, key string, ttl time.Duration) (value int64, err error)
+	Del(ctx context.Context, key string) (err error)
+	// AddToSet adds or refreshes a set member that is dropped after expiresAt.
+	AddToSet(ctx context.Context, key string, member string, expiresAt time.Time) (count int64, err error)
+	RemoveFromSet(ctx context.Context, key string, member string) (count int64, err error)
+	// CountSet counts the members that have not expired yet.
+	CountSet(ctx context.Context, key string) (count int64, err error)
+	// Schedule sets when a member of the schedule at key is due, a member
+	// scheduled again gets the new time.
+	Schedule(ctx context.Context, key string, member string, dueAt time.Time) (err error)
+	Unschedule(ctx context.Context, key string, member string) (err error)
+	// PopDue removes the members due by now and returns them, a member is
+	// only ever returned to one caller.
+	PopDue(ctx context.Context, key string, now time.Time) (members []string, err error)
+}
+
+// SharedState is backed by Redis in production and by MemorySharedState in
+// tests or when the API runs as a single instance.
+type SharedState interface {
+	PubSub
+	KeyValueStore
+}
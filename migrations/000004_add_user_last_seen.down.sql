@@ -0,0 +1,3 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+ALTER TABLE users DROP COLUMN IF EXISTS last_seen_at;
\ No newline at end of file
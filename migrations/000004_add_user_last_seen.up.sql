@@ -0,0 +1,3 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+ALTER TABLE users ADD COLUMN last_seen_at TIMESTAMP NULL;
\ No newline at end of file
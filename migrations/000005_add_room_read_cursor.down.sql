@@ -0,0 +1,4 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+DROP TABLE IF EXISTS room_read_cursors;
+DROP INDEX IF EXISTS idx_room_created_at;
\ No newline at end of file
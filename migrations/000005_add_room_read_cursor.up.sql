@@ -0,0 +1,14 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+CREATE TABLE room_read_cursors (
+    room_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    message_id uuid NOT NULL,
+    read_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(room_id, user_id),
+    CONSTRAINT fk_room_id FOREIGN KEY (room_id) REFERENCES rooms (id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_message_id FOREIGN KEY (message_id) REFERENCES messages (id)
+);
+
+CREATE INDEX idx_room_created_at ON messages(room_id, created_at);
\ No newline at end of file
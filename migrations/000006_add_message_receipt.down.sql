@@ -0,0 +1,3 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+DROP TABLE IF EXISTS message_receipts;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+CREATE TABLE message_receipts (
+    message_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    delivered_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at TIMESTAMP NULL,
+    PRIMARY KEY(message_id, user_id),
+    CONSTRAINT fk_message_id FOREIGN KEY (message_id) REFERENCES messages (id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users (id)
+);
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+ALTER TABLE rooms ADD COLUMN users VARCHAR(254)[] NOT NULL DEFAULT '{}';
+
+UPDATE rooms r SET users = ARRAY(
+    SELECT u.email
+    FROM room_members m
+    JOIN users u ON u.id = m.user_id
+    WHERE m.room_id = r.id
+    ORDER BY m.joined_at
+);
+
+ALTER TABLE rooms ALTER COLUMN users DROP DEFAULT;
+CREATE INDEX idx_users ON rooms(users);
+
+DROP TABLE IF EXISTS room_members;
\ No newline at end of file
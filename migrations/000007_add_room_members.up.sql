@@ -0,0 +1,23 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+CREATE TABLE room_members (
+    room_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(room_id, user_id),
+    CONSTRAINT fk_room_id FOREIGN KEY (room_id) REFERENCES rooms (id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_room_members_user_id ON room_members(user_id);
+
+-- emails without a registered user are dropped, they never had an account to read the room with
+INSERT INTO room_members (room_id, user_id, joined_at)
+SELECT r.id, u.id, r.created_at
+FROM rooms r
+JOIN users u ON u.email = ANY(r.users)
+ON CONFLICT (room_id, user_id) DO NOTHING;
+
+DROP INDEX IF EXISTS idx_users;
+ALTER TABLE rooms DROP COLUMN users;
\ No newline at end of file
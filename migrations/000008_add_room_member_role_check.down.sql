@@ -0,0 +1,3 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+ALTER TABLE room_members DROP CONSTRAINT IF EXISTS chk_role;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+ALTER TABLE room_members ADD CONSTRAINT chk_role CHECK (role IN ('owner', 'admin', 'member'));
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+DROP TABLE IF EXISTS room_invite_redemptions;
+DROP TABLE IF EXISTS room_invites;
\ No newline at end of file
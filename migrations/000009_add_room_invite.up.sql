@@ -0,0 +1,26 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+CREATE TABLE room_invites (
+    id uuid DEFAULT uuid_generate_v4(),
+    room_id uuid NOT NULL,
+    created_by uuid NOT NULL,
+    max_uses INT NOT NULL,
+    uses INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(id),
+    CONSTRAINT fk_room_id FOREIGN KEY (room_id) REFERENCES rooms (id),
+    CONSTRAINT fk_created_by FOREIGN KEY (created_by) REFERENCES users (id)
+);
+
+CREATE INDEX idx_room_invites_room_id ON room_invites(room_id);
+
+CREATE TABLE room_invite_redemptions (
+    invite_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    redeemed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(invite_id, user_id),
+    CONSTRAINT fk_invite_id FOREIGN KEY (invite_id) REFERENCES room_invites (id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users (id)
+);
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+ALTER TABLE messages DROP COLUMN IF EXISTS kind;
+
+ALTER TABLE rooms DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE rooms DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE rooms DROP COLUMN IF EXISTS description;
+ALTER TABLE rooms DROP COLUMN IF EXISTS topic;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+ALTER TABLE rooms ADD COLUMN topic VARCHAR(250) NOT NULL DEFAULT '';
+ALTER TABLE rooms ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE rooms ADD COLUMN avatar_url VARCHAR(500) NOT NULL DEFAULT '';
+ALTER TABLE rooms ADD COLUMN archived_at TIMESTAMP NULL;
+
+-- system messages keep the user that caused them in user_id
+ALTER TABLE messages ADD COLUMN kind VARCHAR(20) NOT NULL DEFAULT 'user';
\ No newline at end of file
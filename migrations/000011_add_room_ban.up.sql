@@ -0,0 +1,13 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+CREATE TABLE room_bans (
+    room_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    banned_by uuid NOT NULL,
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(room_id, user_id),
+    CONSTRAINT fk_room_id FOREIGN KEY (room_id) REFERENCES rooms (id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_banned_by FOREIGN KEY (banned_by) REFERENCES users (id)
+);
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+DROP INDEX IF EXISTS idx_rooms_public_name;
+ALTER TABLE rooms DROP COLUMN IF EXISTS visibility;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+ALTER TABLE rooms ADD COLUMN visibility VARCHAR(20) NOT NULL DEFAULT 'private';
+ALTER TABLE rooms ADD CONSTRAINT chk_visibility CHECK (visibility IN ('private', 'public'));
+
+CREATE INDEX idx_rooms_public_name ON rooms(name) WHERE visibility = 'public';
\ No newline at end of file
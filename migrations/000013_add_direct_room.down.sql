@@ -0,0 +1,8 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+-- merged duplicate direct rooms are not split again
+DROP INDEX IF EXISTS idx_rooms_dm_key;
+ALTER TABLE rooms DROP CONSTRAINT IF EXISTS chk_dm_key;
+ALTER TABLE rooms DROP CONSTRAINT IF EXISTS chk_kind;
+ALTER TABLE rooms DROP COLUMN IF EXISTS dm_key;
+ALTER TABLE rooms DROP COLUMN IF EXISTS kind;
\ No newline at end of file
@@ -0,0 +1,54 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+ALTER TABLE rooms ADD COLUMN kind VARCHAR(20) NOT NULL DEFAULT 'group';
+-- the two member ids in ascending order joined by a colon, set on direct rooms only
+ALTER TABLE rooms ADD COLUMN dm_key VARCHAR(73) NULL;
+
+-- direct rooms were created by the first message between two users, named
+-- 'room' and without an owner, which group rooms always have
+CREATE TEMPORARY TABLE direct_rooms AS
+SELECT r.id AS room_id,
+    (SELECT string_agg(m.user_id::text, ':' ORDER BY m.user_id) FROM room_members m WHERE m.room_id = r.id) AS dm_key,
+    r.created_at
+FROM rooms r
+WHERE r.name = 'room'
+    AND (SELECT COUNT(*) FROM room_members m WHERE m.room_id = r.id) = 2
+    AND NOT EXISTS (SELECT 1 FROM room_members m WHERE m.room_id = r.id AND m.role <> 'member');
+
+-- the oldest room of each pair is kept, the others are merged into it
+CREATE TEMPORARY TABLE direct_room_merges AS
+SELECT room_id, FIRST_VALUE(room_id) OVER (PARTITION BY dm_key ORDER BY created_at, room_id) AS keep_id
+FROM direct_rooms;
+
+DELETE FROM direct_room_merges WHERE room_id = keep_id;
+
+UPDATE messages m SET room_id = d.keep_id
+FROM direct_room_merges d
+WHERE m.room_id = d.room_id;
+
+-- each member keeps the furthest read cursor they had in any of the rooms
+INSERT INTO room_read_cursors (room_id, user_id, message_id, read_at)
+SELECT DISTINCT ON (d.keep_id, c.user_id) d.keep_id, c.user_id, c.message_id, c.read_at
+FROM room_read_cursors c
+JOIN direct_room_merges d ON d.room_id = c.room_id
+JOIN messages lm ON lm.id = c.message_id
+ORDER BY d.keep_id, c.user_id, lm.created_at DESC, lm.id DESC
+ON CONFLICT (room_id, user_id) DO UPDATE SET message_id = EXCLUDED.message_id, read_at = EXCLUDED.read_at
+WHERE (SELECT created_at FROM messages WHERE id = EXCLUDED.message_id) > (SELECT created_at FROM messages WHERE id = room_read_cursors.message_id);
+
+DELETE FROM room_read_cursors c USING direct_room_merges d WHERE c.room_id = d.room_id;
+UPDATE room_invites i SET room_id = d.keep_id FROM direct_room_merges d WHERE i.room_id = d.room_id;
+DELETE FROM room_bans b USING direct_room_merges d WHERE b.room_id = d.room_id;
+DELETE FROM room_members m USING direct_room_merges d WHERE m.room_id = d.room_id;
+DELETE FROM rooms r USING direct_room_merges d WHERE r.id = d.room_id;
+
+UPDATE rooms r SET kind = 'direct', dm_key = d.dm_key
+FROM direct_rooms d
+WHERE r.id = d.room_id;
+
+DROP TABLE direct_room_merges;
+DROP TABLE direct_rooms;
+
+ALTER TABLE rooms ADD CONSTRAINT chk_kind CHECK (kind IN ('group', 'direct'));
+ALTER TABLE rooms ADD CONSTRAINT chk_dm_key CHECK ((kind = 'direct') = (dm_key IS NOT NULL));
+CREATE UNIQUE INDEX idx_rooms_dm_key ON rooms(dm_key);
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+ALTER TABLE room_members DROP COLUMN IF EXISTS muted_until;
+ALTER TABLE room_members DROP COLUMN IF EXISTS muted;
+ALTER TABLE room_members DROP CONSTRAINT IF EXISTS chk_notification_level;
+ALTER TABLE room_members DROP COLUMN IF EXISTS notification_level;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+ALTER TABLE room_members ADD COLUMN notification_level VARCHAR(20) NOT NULL DEFAULT 'all';
+ALTER TABLE room_members ADD CONSTRAINT chk_notification_level CHECK (notification_level IN ('all', 'mentions', 'none'));
+-- a muted member without muted_until stays muted until they unmute the room
+ALTER TABLE room_members ADD COLUMN muted BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE room_members ADD COLUMN muted_until TIMESTAMP NULL;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+ALTER TABLE rooms DROP CONSTRAINT IF EXISTS chk_retention_days;
+ALTER TABLE rooms DROP COLUMN IF EXISTS retention_days;
\ No newline at end of file
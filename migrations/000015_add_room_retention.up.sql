@@ -0,0 +1,5 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+-- messages older than retention_days are purged by the retention sweeper, NULL keeps them forever
+ALTER TABLE rooms ADD COLUMN retention_days INT NULL;
+ALTER TABLE rooms ADD CONSTRAINT chk_retention_days CHECK (retention_days > 0);
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+DROP TABLE IF EXISTS message_revisions;
+ALTER TABLE messages DROP COLUMN IF EXISTS edited;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+ALTER TABLE messages ADD COLUMN edited BOOLEAN NOT NULL DEFAULT false;
+
+-- every version a message had before an edit, content is the replaced text
+CREATE TABLE message_revisions (
+    id uuid DEFAULT uuid_generate_v4(),
+    message_id uuid NOT NULL,
+    content TEXT NOT NULL,
+    edited_by uuid NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(id),
+    CONSTRAINT fk_message_id FOREIGN KEY (message_id) REFERENCES messages (id),
+    CONSTRAINT fk_edited_by FOREIGN KEY (edited_by) REFERENCES users (id)
+);
+
+CREATE INDEX idx_message_revisions_message_id ON message_revisions(message_id, created_at);
\ No newline at end of file
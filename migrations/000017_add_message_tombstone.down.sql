@@ -0,0 +1,5 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS fk_deleted_by;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
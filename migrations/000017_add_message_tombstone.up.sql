@@ -0,0 +1,6 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+-- deleted messages stay as tombstones with an empty content
+ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE messages ADD COLUMN deleted_by uuid NULL;
+ALTER TABLE messages ADD CONSTRAINT fk_deleted_by FOREIGN KEY (deleted_by) REFERENCES users (id);
\ No newline at end of file
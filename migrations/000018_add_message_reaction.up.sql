@@ -0,0 +1,12 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+-- a user reacts at most once with each emoji on a message
+CREATE TABLE message_reactions (
+    message_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    emoji VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(message_id, user_id, emoji),
+    CONSTRAINT fk_message_id FOREIGN KEY (message_id) REFERENCES messages (id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users (id)
+);
\ No newline at end of file
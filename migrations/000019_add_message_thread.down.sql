@@ -0,0 +1,6 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+DROP TABLE IF EXISTS thread_read_cursors;
+DROP INDEX IF EXISTS idx_messages_parent_message_id;
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS fk_parent_message_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS parent_message_id;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+-- replies point at the root message of their thread, root messages have no parent
+ALTER TABLE messages ADD COLUMN parent_message_id uuid NULL;
+ALTER TABLE messages ADD CONSTRAINT fk_parent_message_id FOREIGN KEY (parent_message_id) REFERENCES messages (id);
+
+CREATE INDEX idx_messages_parent_message_id ON messages(parent_message_id, created_at) WHERE parent_message_id IS NOT NULL;
+
+-- threads are read separately from their room
+CREATE TABLE thread_read_cursors (
+    parent_message_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    message_id uuid NOT NULL,
+    read_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(parent_message_id, user_id),
+    CONSTRAINT fk_parent_message_id FOREIGN KEY (parent_message_id) REFERENCES messages (id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_message_id FOREIGN KEY (message_id) REFERENCES messages (id)
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- SQL for the 'down' migration
+-- Add your 'down' migration SQL here
+ALTER TABLE messages DROP COLUMN IF EXISTS reply_to_message_id;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- SQL for the 'up' migration
+-- Add your 'up' migration SQL here
+-- no foreign key on purpose, a quote outlives its original when that one is
+-- purged and then reads as deleted
+ALTER TABLE messages ADD COLUMN reply_to_message_id uuid NULL;
\ No newline at end of file